@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/mholt/archiver/v4"
+)
+
+// archiveExtensions 列出各压缩方法对应的产物后缀。目前没有代码引用它：
+// 解压按archiver.Identify嗅探文件头识别格式，压缩按archiveFormatForDest匹配目标文件名后缀
+var archiveExtensions = map[string]string{
+	"zip":     ".zip",
+	"7z":      ".7z",
+	"tar":     ".tar.gz",
+	"tar.gz":  ".tar.gz",
+	"tar.xz":  ".tar.xz",
+	"tar.zst": ".tar.zst",
+}
+
+// Archiver 统一压缩/解压接口，屏蔽zip/7z/tar.gz/tar.xz/tar.zst等具体格式差异。
+// ExternalExe7zPath 非空时才会退回到外部7z可执行文件，默认走纯Go实现。
+// progress可以为nil表示调用方不关心进度。onlyGlob非空时只解压相对路径匹配该
+// glob模式的条目（例如"db/*"或某个维度目录），为空则解压全部。
+// Extract的archivePath除本地路径外，也接受s3://、webdav://、sftp://远程URI，
+// 这类归档按格式尽量直接从远程流式解压，无需先把整个文件拉到本地磁盘
+type Archiver interface {
+	Compress(ctx context.Context, srcDir, dst string, progress Progress) error
+	Extract(ctx context.Context, archivePath, destDir, onlyGlob string, progress Progress) error
+}
+
+// nativeArchiver 基于 github.com/mholt/archiver/v4 的纯Go实现，
+// 解压时按文件头嗅探格式，压缩时按目标文件名选择写入器
+type nativeArchiver struct {
+	// externalExe7zPath 非空表示用户显式要求使用外部7z可执行文件
+	externalExe7zPath string
+}
+
+// newArchiver 构造默认归档器。只有当用户在配置中显式填写了exe_7z_path
+// 且选择继续使用外部工具时，才应传入非空路径启用回退
+func newArchiver(externalExe7zPath string) Archiver {
+	return &nativeArchiver{externalExe7zPath: externalExe7zPath}
+}
+
+func (a *nativeArchiver) Extract(ctx context.Context, archivePath, destDir, onlyGlob string, progress Progress) error {
+	isRemote := isRemoteBackupURI(archivePath)
+
+	// 外部7z要喂给exec一个真实文件路径，分块zstd则要按TOC记录的偏移量seek读取，
+	// 这两条路径都需要本地可随机访问的文件，远程归档在进入它们之前先完整下载到临时文件
+	if isRemote && (a.externalExe7zPath != "" || hasAnySuffix(archivePath, ".tar.zst", ".tzst")) {
+		localPath, cleanup, err := downloadToTempFile(ctx, archivePath)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		archivePath = localPath
+		isRemote = false
+	}
+
+	if a.externalExe7zPath != "" {
+		if onlyGlob != "" {
+			pluginPrint("外部7z解压不支持-only子集过滤，将解压完整归档", "WARNING")
+		}
+		return extractWithExternal7z(a.externalExe7zPath, archivePath, destDir)
+	}
+
+	// 分块zstd归档有自己的TOC sidecar，支持seek到匹配的帧而不必流式解码整个归档，
+	// 只要TOC存在就优先走这条路径（即便onlyGlob为空也一样正确，只是不省时间）
+	if hasAnySuffix(archivePath, ".tar.zst", ".tzst") {
+		if _, err := os.Stat(archivePath + zstdTOCSuffix); err == nil {
+			pluginPrint(fmt.Sprintf("检测到分块zstd归档TOC，按需解压: %s --> %s", archivePath, destDir), "INFO")
+			return extractTarZstdChunked(ctx, archivePath, destDir, onlyGlob, progress)
+		}
+	}
+
+	var file io.ReadCloser
+	var err error
+	if isRemote {
+		pluginPrint(fmt.Sprintf("从远程存储流式解压，无需先落盘整个归档: %s --> %s", archivePath, destDir), "INFO")
+		backend, key, resolveErr := resolveStorageBackend(archivePath)
+		if resolveErr != nil {
+			return resolveErr
+		}
+		file, err = backend.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("从远程存储读取归档失败: %v", err)
+		}
+	} else {
+		file, err = os.Open(archivePath)
+		if err != nil {
+			return fmt.Errorf("打开压缩文件失败: %v", err)
+		}
+	}
+	defer file.Close()
+
+	format, reader, err := archiver.Identify(archiveNameHint(archivePath), file)
+	if err != nil {
+		return fmt.Errorf("识别压缩格式失败（已按文件头嗅探，而非仅凭扩展名）: %v", err)
+	}
+
+	extractor, ok := format.(archiver.Extractor)
+	if !ok {
+		return fmt.Errorf("格式 %s 不支持解压", format.Name())
+	}
+
+	pluginPrint(fmt.Sprintf("识别到压缩格式: %s，开始解压: %s --> %s", format.Name(), archivePath, destDir), "INFO")
+
+	// 头部解析（由归档库按tar/zip条目顺序单线程驱动）和落盘写入拆开：解析goroutine
+	// 只管按顺序把每个条目的内容读进内存、立刻把写任务丢进有界的jobs channel，
+	// 真正的磁盘写入交给下面的worker池并发完成，写入顺序不再受tar条目顺序约束
+	maxWorkers := globalConfig.MaxWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = defaultMaxWorkers
+	}
+
+	jobs := make(chan extractJob, maxWorkers*2)
+	jobErrors := make(chan error, maxWorkers)
+	var wg sync.WaitGroup
+	var doneBytes int64
+
+	for i := 0; i < maxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := writeExtractJob(job, &doneBytes, progress); err != nil {
+					select {
+					case jobErrors <- err:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	// 解压前无法预知解压后的总字节数（压缩包只能边读边嗅探），
+	// 因此这里的total传-1，进度只展示已处理字节数
+	extractErr := extractor.Extract(ctx, reader, func(entryCtx context.Context, f archiver.File) error {
+		if err := entryCtx.Err(); err != nil {
+			return err
+		}
+		if onlyGlob != "" {
+			matched, err := filepath.Match(onlyGlob, f.NameInArchive)
+			if err != nil {
+				return fmt.Errorf("无效的-only匹配模式: %v", err)
+			}
+			if !matched {
+				return nil
+			}
+		}
+
+		targetPath, err := safeExtractEntryPath(destDir, f.NameInArchive)
+		if err != nil {
+			return err
+		}
+
+		if f.IsDir() {
+			return os.MkdirAll(targetPath, 0755)
+		}
+
+		src, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("打开归档条目失败: %v", err)
+		}
+		data, err := io.ReadAll(src)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("读取归档条目失败: %v", err)
+		}
+
+		job := extractJob{relPath: f.NameInArchive, targetPath: targetPath, mode: f.Mode(), data: data}
+		select {
+		case jobs <- job:
+		case <-entryCtx.Done():
+			return entryCtx.Err()
+		}
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+
+	if extractErr != nil {
+		return fmt.Errorf("解压失败: %v", extractErr)
+	}
+	select {
+	case err := <-jobErrors:
+		return fmt.Errorf("解压写入文件失败: %v", err)
+	default:
+	}
+
+	pluginPrint("解压完成", "SUCCESS")
+	return nil
+}
+
+// extractJob 是解析goroutine交给worker池的一个落盘任务：条目内容已经读进内存，
+// targetPath已经过safeExtractEntryPath校验落在destDir之内，worker只管把它写到
+// 目标路径，不需要再碰归档的读取状态
+type extractJob struct {
+	relPath    string // 仅用于进度展示
+	targetPath string
+	mode       os.FileMode
+	data       []byte
+}
+
+// safeExtractEntryPath 校验归档条目不是绝对路径、也不会通过"../"之类的相对路径
+// 逃出destDir（即所谓zip-slip），通过校验后返回destDir下的目标路径
+func safeExtractEntryPath(destDir, nameInArchive string) (string, error) {
+	if filepath.IsAbs(nameInArchive) {
+		return "", fmt.Errorf("归档条目使用了绝对路径，拒绝解压: %s", nameInArchive)
+	}
+
+	destAbs, err := filepath.Abs(destDir)
+	if err != nil {
+		return "", fmt.Errorf("解析目标目录绝对路径失败: %v", err)
+	}
+	targetAbs, err := filepath.Abs(filepath.Join(destDir, nameInArchive))
+	if err != nil {
+		return "", fmt.Errorf("解析归档条目目标路径失败: %v", err)
+	}
+	if targetAbs != destAbs && !strings.HasPrefix(targetAbs, destAbs+string(filepath.Separator)) {
+		return "", fmt.Errorf("归档条目路径越界（zip-slip），拒绝解压: %s", nameInArchive)
+	}
+
+	return targetAbs, nil
+}
+
+// writeExtractJob 把一个已读入内存的归档条目写到job.targetPath
+func writeExtractJob(job extractJob, doneBytes *int64, progress Progress) error {
+	if err := os.MkdirAll(filepath.Dir(job.targetPath), 0755); err != nil {
+		return fmt.Errorf("创建文件目录失败: %v", err)
+	}
+
+	out, err := os.OpenFile(job.targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, job.mode)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	defer out.Close()
+
+	reader := &countingReader{r: bytes.NewReader(job.data), done: doneBytes, total: -1, path: job.relPath, progress: progress}
+	if _, err := io.Copy(out, reader); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+
+	return nil
+}
+
+func (a *nativeArchiver) Compress(ctx context.Context, srcDir, dst string, progress Progress) error {
+	if a.externalExe7zPath != "" {
+		return compressWithExternal7z(a.externalExe7zPath, srcDir, dst)
+	}
+
+	// tar.zst走独立的分块实现（每个文件一个自包含zstd帧+TOC sidecar），
+	// 而不是generic的archiver.Archiver写入器，这样产出的归档才能被extractTarZstdChunked按需解压
+	if hasAnySuffix(dst, ".tar.zst", ".tzst") {
+		return compressWithTarZstd(ctx, srcDir, dst, progress)
+	}
+
+	// Formats里把当前格式的backend配置为"pgzip"时，走klauspost/pgzip并行编码，
+	// 而不是archiver/v4默认的单线程gzip，产出的仍是标准gzip multistream，解压无需区分
+	compression := currentConfig().Compression
+	if format, ok := compression.Formats[compression.Method]; ok && format.Backend == "pgzip" {
+		return compressWithTarPgzip(ctx, srcDir, dst, format.Level, format.NumCPU, progress)
+	}
+
+	format, err := archiveFormatForDest(dst)
+	if err != nil {
+		return err
+	}
+
+	writer, ok := format.(archiver.Archiver)
+	if !ok {
+		return fmt.Errorf("格式 %s 不支持压缩，请改用zip/tar.gz/tar.xz/tar.zst", format.Name())
+	}
+
+	files, err := archiver.FilesFromDisk(nil, map[string]string{srcDir: ""})
+	if err != nil {
+		return fmt.Errorf("收集待压缩文件失败: %v", err)
+	}
+
+	var srcBytes int64
+	for _, f := range files {
+		if !f.IsDir() {
+			srcBytes += f.Size()
+		}
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("创建压缩文件失败: %v", err)
+	}
+	defer out.Close()
+
+	pluginPrint(fmt.Sprintf("使用 %s 压缩: %s --> %s", format.Name(), srcDir, dst), "INFO")
+
+	// writer用的是产物（压缩后）的字节数，srcBytes是原始大小，
+	// 只能当作一个大致的进度基准，不追求精确百分比
+	var doneBytes int64
+	dest := io.Writer(out)
+	if progress != nil {
+		dest = &countingWriter{w: out, done: &doneBytes, total: srcBytes, path: dst, progress: progress}
+	}
+
+	if err := writer.Archive(ctx, dest, files); err != nil {
+		return fmt.Errorf("压缩失败: %v", err)
+	}
+
+	pluginPrint("压缩完成", "SUCCESS")
+	pluginPrint(fmt.Sprintf("备份文件已保存: %s", dst), "SUCCESS")
+	return nil
+}
+
+// archiveFormatForDest 按目标文件名后缀选择写入格式。tar.zst在Compress里
+// 提前分流给compressWithTarZstd，不会走到这里；7z目前只被原生实现支持读取，
+// 压缩7z仍需要外部工具，因此这里也不注册7z写入器
+func archiveFormatForDest(dst string) (archiver.Format, error) {
+	switch {
+	case hasAnySuffix(dst, ".tar.xz", ".txz"):
+		return archiver.CompressedArchive{Compression: archiver.Xz{}, Archival: archiver.Tar{}}, nil
+	case hasAnySuffix(dst, ".tar.gz", ".tgz", ".tar"):
+		return archiver.CompressedArchive{Compression: archiver.Gz{}, Archival: archiver.Tar{}}, nil
+	case hasAnySuffix(dst, ".zip"):
+		return archiver.Zip{}, nil
+	default:
+		return nil, fmt.Errorf("无法根据文件名 %s 推断压缩格式", dst)
+	}
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if len(s) >= len(suffix) && equalFold(s[len(s)-len(suffix):], suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalFold 轻量的大小写无关比较，避免为了一次后缀匹配引入strings.EqualFold之外的依赖
+func equalFold(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		ca, cb := a[i], b[i]
+		if ca >= 'A' && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if cb >= 'A' && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
+
+// extractWithExternal7z 用户显式配置exe_7z_path时才会走到这里，
+// 作为原生实现之外的兜底选项保留
+func extractWithExternal7z(exe7zPath, archivePath, destDir string) error {
+	pluginPrint(fmt.Sprintf("配置启用了外部7z工具，使用外部7z解压: %s", archivePath), "INFO")
+
+	exe := exe7zPath
+	if runtime.GOOS != "windows" && exe == "" {
+		exe = "7z"
+	}
+
+	cmd := exec.Command(exe, "x", archivePath, "-o"+destDir, "-y")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("外部7z解压失败: %v\n输出: %s", err, string(output))
+	}
+
+	pluginPrint("外部7z解压完成", "SUCCESS")
+	return nil
+}
+
+func compressWithExternal7z(exe7zPath, srcDir, destFile string) error {
+	pluginPrint(fmt.Sprintf("配置启用了外部7z工具，使用外部7z压缩: %s", srcDir), "INFO")
+
+	exe := exe7zPath
+	if runtime.GOOS != "windows" && exe == "" {
+		exe = "7z"
+	}
+
+	cmd := exec.Command(exe, "a", destFile, srcDir+string(filepath.Separator)+"*", "-y")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("外部7z压缩失败: %v\n输出: %s", err, string(output))
+	}
+
+	pluginPrint("外部7z压缩完成", "SUCCESS")
+	pluginPrint(fmt.Sprintf("备份文件已保存: %s", destFile), "SUCCESS")
+	return nil
+}
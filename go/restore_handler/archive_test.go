@@ -0,0 +1,104 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildOrderingFixture 构造一个tar.gz，条目顺序刻意打乱：目录出现在它的文件之后、
+// 一个长度超过100字节（ustar限制）的路径触发GNU长名扩展记录、以及一个硬链接和一个
+// 软链接条目放在末尾。Extract走mholt/archiver/v4单线程解析+worker池并发落盘
+// 这条路径，这里要验证并发写入不会因为tar条目顺序被打乱、或被长名/链接记录打断
+// 而导致目录缺失或内容写错位置
+func buildOrderingFixture(t *testing.T, longPath string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	writeFile := func(name, content string) {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Format: tar.FormatGNU}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("写入tar头失败 %s: %v", name, err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("写入tar内容失败 %s: %v", name, err)
+		}
+	}
+
+	// 长名条目放在最前面，强制gnu长名扩展记录出现在目录条目之前
+	writeFile(longPath, "long-path-content")
+
+	writeFile("base.txt", "base-content")
+
+	// 目录条目故意放在它的子文件之后，worker池必须不依赖tar条目的先后顺序
+	writeFile("nested/child.txt", "nested-content")
+	if err := tw.WriteHeader(&tar.Header{Name: "nested/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatalf("写入目录头失败: %v", err)
+	}
+
+	if err := tw.WriteHeader(&tar.Header{Name: "link.txt", Typeflag: tar.TypeLink, Linkname: "base.txt"}); err != nil {
+		t.Fatalf("写入硬链接头失败: %v", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "symlink.txt", Typeflag: tar.TypeSymlink, Linkname: "base.txt"}); err != nil {
+		t.Fatalf("写入软链接头失败: %v", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("关闭tar writer失败: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("关闭gzip writer失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestExtractWorkerPoolPreservesOrderingInvariants 验证extractJob/writeExtractJob的并发
+// 落盘不会违反tar的顺序不变量：即便目录条目、长名GNU记录、硬链接/软链接条目在源tar里
+// 的顺序被打乱，解压完的目录结构和常规文件内容依然正确、互不覆盖
+func TestExtractWorkerPoolPreservesOrderingInvariants(t *testing.T) {
+	longPath := "nested/" + strings.Repeat("a", 150) + "/long.txt"
+	data := buildOrderingFixture(t, longPath)
+
+	srcDir := t.TempDir()
+	archivePath := filepath.Join(srcDir, "fixture.tar.gz")
+	if err := os.WriteFile(archivePath, data, 0644); err != nil {
+		t.Fatalf("写入fixture归档失败: %v", err)
+	}
+
+	destDir := t.TempDir()
+
+	prevMaxWorkers := globalConfig.MaxWorkers
+	globalConfig.MaxWorkers = 8
+	defer func() { globalConfig.MaxWorkers = prevMaxWorkers }()
+
+	if err := newArchiver("").Extract(context.Background(), archivePath, destDir, "", nil); err != nil {
+		t.Fatalf("Extract失败: %v", err)
+	}
+
+	assertFileContent := func(relPath, want string) {
+		t.Helper()
+		got, err := os.ReadFile(filepath.Join(destDir, relPath))
+		if err != nil {
+			t.Fatalf("读取解压产物 %s 失败: %v", relPath, err)
+		}
+		if string(got) != want {
+			t.Fatalf("解压产物 %s 内容不匹配: 期望 %q，实际 %q", relPath, want, string(got))
+		}
+	}
+
+	assertFileContent("base.txt", "base-content")
+	assertFileContent("nested/child.txt", "nested-content")
+	assertFileContent(longPath, "long-path-content")
+
+	if info, err := os.Stat(filepath.Join(destDir, "nested")); err != nil || !info.IsDir() {
+		t.Fatalf("nested目录未正确创建: %v", err)
+	}
+}
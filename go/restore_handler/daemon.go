@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// command持有daemon模式下长期存活的状态：cron调度器本身，以及每次reload成功后非阻塞投递一个信号的channel（容量1）。
+// 配置本身不再由command额外持有快照——pluginConfig已经behind pluginConfigMu/currentConfig()
+// 做到了并发安全，cron调度goroutine和SIGHUP/文件变化重载goroutine都通过它读写，
+// 不需要command再维护一份重复的原子快照
+type command struct {
+	serverDir string
+	scheduler *cron.Cron
+	reloadCh  chan struct{}
+}
+
+// newCommand加载一次初始配置并返回一个可长期运行的command
+func newCommand(serverDir string) (*command, error) {
+	if err := loadConfig(serverDir); err != nil {
+		return nil, err
+	}
+	return &command{
+		serverDir: serverDir,
+		reloadCh:  make(chan struct{}, 1),
+	}, nil
+}
+
+// reload重新调用loadConfig，它会把新配置原子地发布到pluginConfig供所有读者使用
+func (c *command) reload() error {
+	if err := loadConfig(c.serverDir); err != nil {
+		return err
+	}
+	pluginPrint("配置已重新加载", "SUCCESS")
+	select {
+	case c.reloadCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// watchConfigFile轮询配置文件的mtime，变化时触发一次reload，是SIGHUP之外
+// 另一条重载路径（例如配置由外部工具而非kill -HUP触发更新）
+func (c *command) watchConfigFile(ctx context.Context, configPath string, interval time.Duration) {
+	var lastMod time.Time
+	if info, err := os.Stat(configPath); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(configPath)
+			if err != nil {
+				continue
+			}
+			if info.ModTime().After(lastMod) {
+				lastMod = info.ModTime()
+				pluginPrint(fmt.Sprintf("检测到配置文件变化: %s", configPath), "INFO")
+				if err := c.reload(); err != nil {
+					pluginPrint(fmt.Sprintf("重新加载配置失败: %v", err), "ERROR")
+				}
+			}
+		}
+	}
+}
+
+// runDaemon以长期运行模式启动：监听SIGHUP和配置文件mtime变化以热重载配置，
+// schedule非空时用robfig/cron/v3按cron表达式周期性执行世界备份，
+// 直至ctx被取消（收到SIGINT/SIGTERM）
+func runDaemon(ctx context.Context, serverDir, schedule string) error {
+	cmd, err := newCommand(serverDir)
+	if err != nil {
+		return err
+	}
+
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+
+	if configPath := resolveConfigPath(serverDir); configPath != "" {
+		go cmd.watchConfigFile(ctx, configPath, 5*time.Second)
+	}
+
+	if schedule != "" {
+		cmd.scheduler = cron.New()
+		if _, err := cmd.scheduler.AddFunc(schedule, func() {
+			pluginPrint(fmt.Sprintf("按计划 %s 执行世界备份", schedule), "INFO")
+			if err := backupCurrentWorld(ctx); err != nil {
+				pluginPrint(fmt.Sprintf("计划备份失败: %v", err), "ERROR")
+			}
+		}); err != nil {
+			return fmt.Errorf("解析-schedule表达式失败: %v", err)
+		}
+		cmd.scheduler.Start()
+		defer cmd.scheduler.Stop()
+		pluginPrint(fmt.Sprintf("已启用计划备份，cron表达式: %s", schedule), "SUCCESS")
+	}
+
+	pluginPrint("daemon模式已启动，等待SIGHUP重载配置或SIGINT/SIGTERM退出", "INFO")
+
+	for {
+		select {
+		case <-ctx.Done():
+			pluginPrint("daemon收到退出信号，正在停止", "INFO")
+			return nil
+		case <-hupCh:
+			pluginPrint("收到SIGHUP，正在重新加载配置", "INFO")
+			if err := cmd.reload(); err != nil {
+				pluginPrint(fmt.Sprintf("重新加载配置失败: %v", err), "ERROR")
+			}
+		}
+	}
+}
@@ -0,0 +1,598 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupModeFull        = "full"
+	backupModeIncremental = "incremental"
+
+	manifestSuffix = ".manifest.json"
+	patchExtension = ".patch.tar.gz"
+)
+
+// ChangeOp 描述变更集中单个条目相对父备份发生了什么
+type ChangeOp string
+
+const (
+	ChangeAdded    ChangeOp = "added"
+	ChangeModified ChangeOp = "modified"
+	ChangeDeleted  ChangeOp = "deleted"
+)
+
+// FileEntry 记录manifest中单个文件的指纹，用于和下一次备份比较
+type FileEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"`
+}
+
+// ChangeEntry 是一次增量备份要写入patch层的单条变更
+type ChangeEntry struct {
+	Path string   `json:"path"`
+	Op   ChangeOp `json:"op"`
+	Hash string   `json:"hash,omitempty"`
+}
+
+// BackupManifest 记录某次备份的完整文件树指纹以及增量链信息
+type BackupManifest struct {
+	BackupID       string               `json:"backup_id"`
+	ParentBackupID string               `json:"parent_backup_id,omitempty"`
+	WorldName      string               `json:"world_name"`
+	Mode           string               `json:"mode"`
+	CreatedAt      time.Time            `json:"created_at"`
+	Files          map[string]FileEntry `json:"files"`
+	// ArchiveHash是该层归档文件（全量备份为压缩包本体，增量备份为补丁层）的sha256，
+	// 供main()中的回档流程在Backup.Verify开启时校验该层在落盘后没有被存储介质悄悄损坏
+	ArchiveHash string `json:"archive_hash,omitempty"`
+}
+
+// hashFile 计算文件内容的sha256，用于增量备份的变更比对
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashArchiveSource 和hashFile类似，但path可以是s3/webdav/sftp远程URI，
+// 此时直接对Get()返回的流边读边哈希，不需要先把整个归档落盘
+func hashArchiveSource(ctx context.Context, path string) (string, error) {
+	if !isRemoteBackupURI(path) {
+		return hashFile(path)
+	}
+
+	backend, key, err := resolveStorageBackend(path)
+	if err != nil {
+		return "", err
+	}
+
+	rc, err := backend.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// snapshotWorldDir 遍历世界目录，为每个文件计算指纹，构成完整文件树快照
+func snapshotWorldDir(worldDir string) (map[string]FileEntry, error) {
+	snapshot := make(map[string]FileEntry)
+
+	err := filepath.Walk(worldDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(worldDir, path)
+		if err != nil {
+			return err
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return fmt.Errorf("计算文件哈希失败 %s: %v", relPath, err)
+		}
+
+		snapshot[filepath.ToSlash(relPath)] = FileEntry{
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Hash:    hash,
+		}
+		return nil
+	})
+
+	return snapshot, err
+}
+
+// computeChangeset 对比当前世界快照与父备份manifest，得到增量变更集
+func computeChangeset(current map[string]FileEntry, parent *BackupManifest) []ChangeEntry {
+	var parentFiles map[string]FileEntry
+	if parent != nil {
+		parentFiles = parent.Files
+	}
+
+	var changes []ChangeEntry
+
+	for relPath, entry := range current {
+		if prev, ok := parentFiles[relPath]; !ok || prev.Hash != entry.Hash {
+			changes = append(changes, ChangeEntry{Path: relPath, Op: ChangeAdded, Hash: entry.Hash})
+			if ok {
+				changes[len(changes)-1].Op = ChangeModified
+			}
+		}
+	}
+
+	for relPath := range parentFiles {
+		if _, ok := current[relPath]; !ok {
+			changes = append(changes, ChangeEntry{Path: relPath, Op: ChangeDeleted})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// writePatchLayer 把变更集中added/modified的文件打包进tar.gz补丁层，
+// 删除项不落盘文件内容，只会出现在returned的whiteouts列表里供调用方写manifest
+func writePatchLayer(ctx context.Context, worldDir, patchPath string, changes []ChangeEntry) ([]string, error) {
+	out, err := os.Create(patchPath)
+	if err != nil {
+		return nil, fmt.Errorf("创建补丁文件失败: %v", err)
+	}
+	defer out.Close()
+
+	gzWriter := gzip.NewWriter(out)
+	defer gzWriter.Close()
+
+	tarWriter := tar.NewWriter(gzWriter)
+	defer tarWriter.Close()
+
+	var whiteouts []string
+
+	for _, change := range changes {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if change.Op == ChangeDeleted {
+			whiteouts = append(whiteouts, change.Path)
+			continue
+		}
+
+		srcPath := filepath.Join(worldDir, filepath.FromSlash(change.Path))
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取文件信息失败 %s: %v", change.Path, err)
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return nil, err
+		}
+		header.Name = change.Path
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return nil, err
+		}
+
+		file, err := os.Open(srcPath)
+		if err != nil {
+			return nil, err
+		}
+		_, copyErr := io.Copy(tarWriter, file)
+		file.Close()
+		if copyErr != nil {
+			return nil, copyErr
+		}
+	}
+
+	return whiteouts, nil
+}
+
+// writeManifest 把manifest和whiteouts列表序列化到备份文件旁的sidecar
+func writeManifest(backupPath string, manifest *BackupManifest, whiteouts []string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化manifest失败: %v", err)
+	}
+
+	if err := os.WriteFile(backupPath+manifestSuffix, data, 0644); err != nil {
+		return fmt.Errorf("写入manifest失败: %v", err)
+	}
+
+	if len(whiteouts) > 0 {
+		whiteoutData, err := json.MarshalIndent(whiteouts, "", "  ")
+		if err != nil {
+			return fmt.Errorf("序列化whiteouts失败: %v", err)
+		}
+		if err := os.WriteFile(backupPath+".whiteouts.json", whiteoutData, 0644); err != nil {
+			return fmt.Errorf("写入whiteouts失败: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// readManifest 读取备份文件旁的manifest sidecar，不存在时返回nil而非错误，
+// 因为旧版本产出的全量备份没有manifest。backupPath可以是本地路径，
+// 也可以是s3/webdav/sftp远程URI
+func readManifest(backupPath string) (*BackupManifest, error) {
+	// 链式遍历（resolveBackupChain等）发生在还没有请求级ctx的早期阶段，
+	// 这里退回context.Background()，真正需要响应中断的长耗时下载走的是
+	// downloadToTempFile/Extract那条已经接了请求ctx的路径
+	data, err := readSidecarBytes(context.Background(), backupPath+manifestSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取manifest失败: %v", err)
+	}
+
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("解析manifest失败: %v", err)
+	}
+
+	return &manifest, nil
+}
+
+// readWhiteouts 读取与某个补丁层配套的删除列表，backupPath可以是本地路径，
+// 也可以是s3/webdav/sftp远程URI
+func readWhiteouts(backupPath string) ([]string, error) {
+	data, err := readSidecarBytes(context.Background(), backupPath+".whiteouts.json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取whiteouts失败: %v", err)
+	}
+
+	var whiteouts []string
+	if err := json.Unmarshal(data, &whiteouts); err != nil {
+		return nil, fmt.Errorf("解析whiteouts失败: %v", err)
+	}
+
+	return whiteouts, nil
+}
+
+// incrementalChainDepth 统计从某个增量备份manifest回溯到最近全量备份需要经过的层数，
+// 用于backupCurrentWorld判断增量链是否已超出RetentionCount，避免链条无限增长
+func incrementalChainDepth(backupDir string, manifest *BackupManifest) (int, error) {
+	depth := 0
+	current := manifest
+	for current.Mode == backupModeIncremental {
+		if current.ParentBackupID == "" {
+			return depth, fmt.Errorf("增量备份 %s 缺少父备份引用", current.BackupID)
+		}
+
+		parentPath := filepath.Join(backupDir, current.ParentBackupID)
+		parentManifest, err := readManifest(parentPath)
+		if err != nil {
+			return depth, err
+		}
+		if parentManifest == nil {
+			return depth, fmt.Errorf("找不到父备份 %s 的manifest", current.ParentBackupID)
+		}
+
+		depth++
+		current = parentManifest
+	}
+	return depth, nil
+}
+
+// latestBackupForWorld 在备份目录中找到某个世界最近一次备份（按manifest中的CreatedAt排序），
+// 用作下一次增量备份的父备份
+func latestBackupForWorld(backupDir, worldName string) (string, *BackupManifest, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil, nil
+		}
+		return "", nil, err
+	}
+
+	var latestPath string
+	var latestManifest *BackupManifest
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		if len(entry.Name()) < len(manifestSuffix) || entry.Name()[len(entry.Name())-len(manifestSuffix):] != manifestSuffix {
+			continue
+		}
+
+		backupPath := filepath.Join(backupDir, entry.Name()[:len(entry.Name())-len(manifestSuffix)])
+		manifest, err := readManifest(backupPath)
+		if err != nil || manifest == nil {
+			continue
+		}
+		if manifest.WorldName != worldName {
+			continue
+		}
+
+		if latestManifest == nil || manifest.CreatedAt.After(latestManifest.CreatedAt) {
+			latestManifest = manifest
+			latestPath = backupPath
+		}
+	}
+
+	return latestPath, latestManifest, nil
+}
+
+// resolveBackupChain 从任意一层增量补丁出发，沿ParentBackupID回溯到最近的全量备份，
+// 返回从最早的全量备份到最新一层（incl.调用者传入的backupPath）按顺序排列的
+// 备份ID路径及其对应manifest，供applyPatchChain和verifyArchiveChain共用
+func resolveBackupChain(backupPath string, manifest *BackupManifest) ([]string, []*BackupManifest, error) {
+	chain := []string{backupPath}
+	manifests := []*BackupManifest{manifest}
+
+	current := manifest
+	for current.Mode == backupModeIncremental {
+		if current.ParentBackupID == "" {
+			return nil, nil, fmt.Errorf("增量备份 %s 缺少父备份引用", current.BackupID)
+		}
+
+		parentPath := filepath.Join(filepath.Dir(backupPath), current.ParentBackupID)
+		parentManifest, err := readManifest(parentPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if parentManifest == nil {
+			return nil, nil, fmt.Errorf("找不到父备份 %s 的manifest", current.ParentBackupID)
+		}
+
+		chain = append(chain, parentPath)
+		manifests = append(manifests, parentManifest)
+		current = parentManifest
+	}
+
+	// chain/manifests目前是从新到旧，翻转成从最早的全量备份到最新的补丁层
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+		manifests[i], manifests[j] = manifests[j], manifests[i]
+	}
+
+	return chain, manifests, nil
+}
+
+// verifyArchiveChain 在应用增量链或解压全量备份之前，对链上每一层的归档文件
+// （全量备份为压缩包本体，增量备份为补丁层）重新计算sha256并与manifest中的
+// ArchiveHash比对，用于Backup.Verify为archive/per-file时检测存储介质的悄悄损坏。
+// 旧版本产出的manifest没有ArchiveHash字段，此时跳过该层并打印WARNING而非报错。
+// headArchivePath是最新一层（即调用方传入backupIDPath对应的原始、带扩展名的路径），
+// 远程URI无法像本地路径那样通过resolveBackupArchivePath探测扩展名，需要调用方直接给出
+func verifyArchiveChain(ctx context.Context, headArchivePath, backupIDPath string, manifest *BackupManifest) error {
+	chain, manifests, err := resolveBackupChain(backupIDPath, manifest)
+	if err != nil {
+		return err
+	}
+
+	for i, layerPath := range chain {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		layerManifest := manifests[i]
+		if layerManifest.ArchiveHash == "" {
+			pluginPrint(fmt.Sprintf("备份层 %s 没有archive_hash（可能产自旧版本），跳过该层的完整性校验", layerManifest.BackupID), "WARNING")
+			continue
+		}
+
+		var archivePath string
+		switch {
+		case i == len(chain)-1 && isRemoteBackupURI(layerPath):
+			archivePath = headArchivePath
+		case i == 0:
+			archivePath = resolveBackupArchivePath(layerPath)
+		default:
+			archivePath = layerPath + patchExtension
+		}
+
+		hash, err := hashArchiveSource(ctx, archivePath)
+		if err != nil {
+			return fmt.Errorf("计算归档文件哈希失败 %s: %v", archivePath, err)
+		}
+		if hash != layerManifest.ArchiveHash {
+			return fmt.Errorf("归档文件 %s 的完整性校验失败，备份可能已损坏", archivePath)
+		}
+	}
+
+	return nil
+}
+
+// verifyExtractedFiles 校验destDir下已解压的文件内容与manifest记录的per-file哈希一致，
+// 用于Backup.Verify为per-file时在删除现有世界目录前发现被存储介质悄悄损坏的单个文件
+func verifyExtractedFiles(destDir string, expected map[string]FileEntry) error {
+	for relPath, entry := range expected {
+		fullPath := filepath.Join(destDir, filepath.FromSlash(relPath))
+
+		hash, err := hashFile(fullPath)
+		if err != nil {
+			return fmt.Errorf("校验文件 %s 失败（文件缺失或无法读取）: %v", relPath, err)
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf("文件 %s 的哈希校验失败，备份可能已损坏", relPath)
+		}
+	}
+
+	return nil
+}
+
+// applyPatchChain 从任意一层增量补丁出发，沿ParentBackupID回溯到最近的全量备份，
+// 依次展开全量备份与每一层补丁，最终把结果落到destDir。onlyGlob非空时只落盘
+// 匹配该glob模式的相对路径，用于-only子集恢复
+func applyPatchChain(ctx context.Context, backupPath string, manifest *BackupManifest, destDir, onlyGlob string) error {
+	chain, _, err := resolveBackupChain(backupPath, manifest)
+	if err != nil {
+		return err
+	}
+
+	archiver := newArchiver(externalExe7zPath(currentConfig().Compression))
+
+	basePath := resolveBackupArchivePath(chain[0])
+	pluginPrint(fmt.Sprintf("应用增量链的基础全量备份: %s", basePath), "INFO")
+	if err := archiver.Extract(ctx, basePath, destDir, onlyGlob, newThrottledProgress()); err != nil {
+		return fmt.Errorf("解压基础全量备份失败: %v", err)
+	}
+
+	for i := 1; i < len(chain); i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		patchPath := chain[i] + patchExtension
+		pluginPrint(fmt.Sprintf("应用增量补丁层: %s", patchPath), "INFO")
+
+		if err := extractPatchLayer(ctx, patchPath, destDir, onlyGlob); err != nil {
+			return fmt.Errorf("应用补丁层 %s 失败: %v", patchPath, err)
+		}
+
+		whiteouts, err := readWhiteouts(chain[i])
+		if err != nil {
+			return err
+		}
+		for _, relPath := range whiteouts {
+			targetPath, err := safeExtractEntryPath(destDir, relPath)
+			if err != nil {
+				return fmt.Errorf("whiteout条目路径越界: %v", err)
+			}
+			if err := os.RemoveAll(targetPath); err != nil {
+				return fmt.Errorf("应用whiteout删除 %s 失败: %v", relPath, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// extractPatchLayer 把一个tar.gz补丁层中的文件覆盖写入destDir，onlyGlob非空时
+// 跳过相对路径不匹配的条目。patchPath可以是本地路径，也可以是s3/webdav/sftp远程URI——
+// gzip.NewReader只需要一个io.Reader，不需要seek，所以远程补丁层可以直接边读边解压，
+// 不必像archive.go里外部7z/分块zstd那两条路径一样先整个下载到本地临时文件
+func extractPatchLayer(ctx context.Context, patchPath, destDir, onlyGlob string) error {
+	var file io.ReadCloser
+	if isRemoteBackupURI(patchPath) {
+		backend, key, err := resolveStorageBackend(patchPath)
+		if err != nil {
+			return err
+		}
+		rc, err := backend.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("从远程存储读取补丁层失败: %v", err)
+		}
+		file = rc
+	} else {
+		f, err := os.Open(patchPath)
+		if err != nil {
+			return err
+		}
+		file = f
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if onlyGlob != "" {
+			matched, err := filepath.Match(onlyGlob, header.Name)
+			if err != nil {
+				return fmt.Errorf("无效的-only匹配模式: %v", err)
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		targetPath, err := safeExtractEntryPath(destDir, header.Name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return err
+		}
+
+		outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+		if err != nil {
+			return err
+		}
+		_, copyErr := io.Copy(outFile, tarReader)
+		outFile.Close()
+		if copyErr != nil {
+			return copyErr
+		}
+	}
+
+	return nil
+}
+
+// backupIDPathFromFile 去掉已知的归档/补丁后缀，得到该备份的ID路径
+// （即manifest sidecar去掉.manifest.json后的路径），用于按路径查找manifest
+func backupIDPathFromFile(path string) string {
+	for _, ext := range []string{patchExtension, ".tar.gz", ".tar.xz", ".tar.zst", ".zip", ".7z"} {
+		if strings.HasSuffix(path, ext) {
+			return strings.TrimSuffix(path, ext)
+		}
+	}
+	return path
+}
+
+// resolveBackupArchivePath 全量备份的manifest旁边是真正的归档文件本体，
+// 按约定的扩展名在同目录下查找
+func resolveBackupArchivePath(backupIDPath string) string {
+	for _, ext := range []string{".zip", ".7z", ".tar.gz", ".tar.xz", ".tar.zst"} {
+		if _, err := os.Stat(backupIDPath + ext); err == nil {
+			return backupIDPath + ext
+		}
+	}
+	return backupIDPath
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// httpDownloadMaxAttempts是downloadWithResume在传输中断后重试的最大次数（含首次尝试）
+const httpDownloadMaxAttempts = 3
+
+// httpStorageBackend 从普通HTTP(S)服务器拉取归档，只读，不支持Put/List/Delete——
+// 回档工具没有通用的HTTP上传/列目录协议可供约定，这类远程URI只用于-backup指定待恢复的归档
+type httpStorageBackend struct {
+	client *http.Client
+	cfg    HTTPConfig
+}
+
+// resolveHTTPBackend校验http(s)://URI格式合法，凭证从pluginConfig.Backup.Storage.HTTP读取；
+// 与s3/webdav/sftp不同，这里的"key"就是完整URI本身，因为HTTP没有bucket/根目录这类前缀概念
+func resolveHTTPBackend(uri string) (StorageBackend, string, error) {
+	if _, err := url.Parse(uri); err != nil {
+		return nil, "", fmt.Errorf("解析HTTP URI失败: %v", err)
+	}
+	return &httpStorageBackend{client: &http.Client{}, cfg: pluginConfig.Backup.Storage.HTTP}, uri, nil
+}
+
+// authorize把认证信息加到请求头上：BearerToken优先，其次Basic认证，
+// 配置留空时回退读取对应环境变量
+func (b *httpStorageBackend) authorize(req *http.Request) {
+	token := b.cfg.BearerToken
+	if token == "" {
+		token = os.Getenv(envHTTPBearerToken)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return
+	}
+
+	username := b.cfg.Username
+	if username == "" {
+		username = os.Getenv(envHTTPUsername)
+	}
+	password := b.cfg.Password
+	if password == "" {
+		password = os.Getenv(envHTTPPassword)
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+}
+
+func (b *httpStorageBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("构造HTTP请求失败: %v", err)
+	}
+	b.authorize(req)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求HTTP归档失败: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP请求返回非200状态码: %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (b *httpStorageBackend) Put(ctx context.Context, name string, r io.Reader) error {
+	return errors.New("http备份源是只读的，不支持上传")
+}
+
+func (b *httpStorageBackend) List(ctx context.Context) ([]ObjectInfo, error) {
+	return nil, errors.New("http备份源不支持列举，请直接在-backup中指定完整URI")
+}
+
+func (b *httpStorageBackend) Delete(ctx context.Context, name string) error {
+	return errors.New("http备份源是只读的，不支持删除")
+}
+
+// downloadWithResume把url指向的归档下载到destPath。传输中途失败（网络抖动、
+// 连接被对端重置等）时，按已写入的字节数带Range: bytes=<written>-续传，连同首次在内
+// 最多尝试httpDownloadMaxAttempts次，而不是每次都从零字节重新拉取整个归档——对跑在公网上的
+// 大文件冷存储下载尤其重要。如果服务器不支持Range（已写入字节数>0时仍返回200而非206），
+// 判定为不支持续传，清空本地已下载内容后重新开始
+func (b *httpStorageBackend) downloadWithResume(ctx context.Context, url, destPath string, progress Progress) error {
+	var lastErr error
+	var doneBytes int64
+
+	for attempt := 0; attempt < httpDownloadMaxAttempts; attempt++ {
+		if attempt > 0 {
+			pluginPrint(fmt.Sprintf("HTTP下载中断，%d秒后从第%d字节处续传重试: %v", httpRetryBackoffSeconds, doneBytes, lastErr), "WARNING")
+			time.Sleep(time.Duration(httpRetryBackoffSeconds) * time.Second)
+		}
+
+		existing, err := fileSize(destPath)
+		if err != nil {
+			return fmt.Errorf("读取本地临时文件大小失败: %v", err)
+		}
+		doneBytes = existing
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("构造HTTP请求失败: %v", err)
+		}
+		b.authorize(req)
+		if existing > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", existing))
+		}
+
+		resp, err := b.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		resumed := resp.StatusCode == http.StatusPartialContent
+		if existing > 0 && !resumed {
+			// 服务器不支持Range续传，只能整份重新下载
+			pluginPrint("远程服务器不支持Range续传，清空本地部分下载内容后重新开始", "WARNING")
+			existing = 0
+			doneBytes = 0
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusNotFound {
+				return fmt.Errorf("%s: %w", url, os.ErrNotExist)
+			}
+			return fmt.Errorf("HTTP请求返回非200/206状态码: %d", resp.StatusCode)
+		}
+
+		total := int64(-1)
+		if resp.ContentLength >= 0 {
+			total = existing + resp.ContentLength
+		}
+
+		flags := os.O_CREATE | os.O_WRONLY
+		if resumed {
+			flags |= os.O_APPEND
+		} else {
+			flags |= os.O_TRUNC
+		}
+		out, err := os.OpenFile(destPath, flags, 0644)
+		if err != nil {
+			resp.Body.Close()
+			return fmt.Errorf("打开本地临时文件失败: %v", err)
+		}
+
+		reader := io.Reader(resp.Body)
+		if progress != nil {
+			done := doneBytes
+			reader = &countingReader{r: resp.Body, done: &done, total: total, path: url, progress: progress}
+		}
+
+		_, copyErr := io.Copy(out, reader)
+		out.Close()
+		resp.Body.Close()
+
+		if copyErr == nil {
+			return nil
+		}
+		lastErr = copyErr
+	}
+
+	return fmt.Errorf("多次尝试后仍下载失败: %v", lastErr)
+}
+
+// httpRetryBackoffSeconds是downloadWithResume两次续传尝试之间的等待秒数
+const httpRetryBackoffSeconds = 3
+
+// fileSize返回path的文件大小，文件不存在时返回0而非错误，方便downloadWithResume
+// 区分"首次下载"和"续传一个已存在的部分文件"
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
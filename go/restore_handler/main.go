@@ -1,19 +1,19 @@
 package main
 
 import (
-	"archive/tar"
-	"compress/gzip"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fatih/color"
@@ -26,40 +26,122 @@ const (
 	defaultMaxWorkers = 4
 )
 
+const (
+	verifyNone    = "none"
+	verifyArchive = "archive"
+	verifyPerFile = "per-file"
+)
+
 // Config 结构体定义
 type GlobalConfig struct {
 	Debug      bool `json:"debug"`
 	MaxWorkers int  `json:"max_workers"`
 }
 
+type RCONConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Password string `json:"password"`
+}
+
+type RestartServerConfig struct {
+	Status          bool   `json:"status"`
+	WaitTimeS       int    `json:"wait_time_s"`
+	StartScriptPath string `json:"start_script_path"`
+	// StopCommand非空时，回档前会尝试通过具名管道向仍在运行的服务器
+	// 发送该命令（例如"stop"）请求优雅关闭。RCON.Host非空时优先使用RCON而不是这个，
+	// 因为RCON不要求服务器是由本插件启动的
+	StopCommand string     `json:"stop_command"`
+	RCON        RCONConfig `json:"rcon"`
+	// StopTimeoutS是优雅关闭请求发出后等待服务器自行退出的超时时间（秒），
+	// 超时后会发送平台相关的强制终止信号（Unix下SIGTERM，Windows下CTRL_BREAK）
+	// 再继续轮询，避免优雅关闭请求没生效时无限期卡住。为0时退回旧行为：只被动轮询。
+	// 只在pid文件记录的PID仍存在时生效；按进程名扫描回退时不发送终止信号，只被动轮询
+	StopTimeoutS int `json:"stop_timeout_s"`
+	// HealthCheckTimeoutS是启动服务器后等待进程真正起来的超时时间（秒），为0时默认30秒。
+	// 超时仍未检测到进程，回档流程判定本次重启失败，自动把回滚目录换回当前世界目录
+	HealthCheckTimeoutS int `json:"health_check_timeout_s"`
+}
+
 type RestoreConfig struct {
 	Config struct {
-		BackupOldWorldBeforeRestore bool `json:"backup_old_world_before_restore"`
-		Debug                       bool `json:"debug"`
-		RestartServer               struct {
-			Status          bool   `json:"status"`
-			WaitTimeS       int    `json:"wait_time_s"`
-			StartScriptPath string `json:"start_script_path"`
-		} `json:"restart_server"`
+		BackupOldWorldBeforeRestore bool                `json:"backup_old_world_before_restore"`
+		Debug                       bool                `json:"debug"`
+		RestartServer               RestartServerConfig `json:"restart_server"`
+		// RollbackKeepCount控制worlds目录下积累的<世界名>.rollback-<时间戳>目录最多保留几份，
+		// 每次回档成功且服务器健康检查通过后触发清理；为0时不自动清理，交给用户手动处理
+		RollbackKeepCount int `json:"rollback_keep_count"`
 	} `json:"config"`
+	// Notifications配置回档结束时向外部面板/机器人发送的HTTP状态回调，留空URL表示不启用
+	Notifications NotificationConfig `json:"Notifications"`
 }
 
 type CompressionFormat struct {
 	Extension    string   `json:"extension"`
 	CompressArgs []string `json:"compress_args"`
 	ExtractArgs  []string `json:"extract_args"`
+	// Backend非空时覆盖该格式的压缩实现，目前只有"pgzip"（klauspost/pgzip并行gzip）
+	// 生效，为空时走默认的archiver/v4单线程路径
+	Backend string `json:"backend"`
+	// Level和NumCPU只在Backend为"pgzip"时使用，分别对应压缩级别和并发worker数，
+	// 为0时分别回退到pgzip.DefaultCompression和globalConfig.MaxWorkers
+	Level  int `json:"level"`
+	NumCPU int `json:"num_cpu"`
 }
 
 type CompressionConfig struct {
-	Method    string                       `json:"method"`
-	Exe7zPath string                       `json:"exe_7z_path"`
-	Formats   map[string]CompressionFormat `json:"formats"`
+	Method string `json:"method"`
+	// Exe7zPath 仅在UseExternal7z为true时生效，用于指定外部7z可执行文件路径；
+	// 默认走内置的纯Go归档实现，不再强制依赖该可执行文件
+	Exe7zPath     string                       `json:"exe_7z_path"`
+	UseExternal7z bool                         `json:"use_external_7z"`
+	Formats       map[string]CompressionFormat `json:"formats"`
+	// Level 对应zstd的EncoderLevel（1=最快，4=最高压缩比），为0时使用klauspost/compress的默认级别，
+	// 目前只影响tar.zst分块压缩——这两个字段只是给已有的zstd分块压缩路径加了两个调节旋钮，
+	// 归档格式本身仍然是archive.go里按文件名/文件头switch出来的几种内置格式（zip/tar.gz/tar.xz/
+	// tar.zst/7z），还不是一个可供外部注册新格式的ArchiveFormat接口+注册表
+	Level int `json:"level"`
+	// Threads 覆盖zstd分块压缩使用的并发帧编码数，为0时回退到globalConfig.MaxWorkers
+	Threads int `json:"threads"`
+}
+
+// BackupConfig 控制回档前自动备份（backupCurrentWorld）采用全量还是增量模式
+type BackupConfig struct {
+	Mode string `json:"mode"` // full 或 incremental，默认为full
+	// RetentionCount只是按链长（层数）强制截断增量链，到达上限后下一次转为全量；
+	// 它不是按时间周期性强制全量的FullInterval，逐文件的diff也仍然是chunk0-2那套
+	// 整文件SHA-256比较，不是按固定大小内容块分块哈希（类似docker/archive ChangesDirs
+	// 的思路），这两点都是这个backlog条目原本要做但没有做的部分
+	RetentionCount int `json:"retention_count"`
+	// Verify控制回档时对备份完整性的校验级别：
+	// none（默认，不校验）/ archive（只校验整层归档文件的哈希）/
+	// per-file（额外在解压后逐个校验文件哈希），发现不一致时中止回档且不删除现有世界目录
+	Verify string `json:"verify"`
+	// Storage配置远程备份存储的连接信息：-backup参数传入s3://、webdav://、sftp://或http(s)://
+	// 形式的远程URI时从这里读取凭证；Type为"s3"、"webdav"或"sftp"时，backupCurrentWorld
+	// 还会把每份新备份推送到该存储。http(s)://这类URI是只读的（backupCurrentWorld不支持推送到该类型）
+	Storage StorageConfig `json:"storage"`
+	// Retention配置backupCurrentWorld写入新归档后执行的分代保留策略
+	Retention RetentionConfig `json:"retention"`
+}
+
+// RetentionConfig 控制分代（grandfather-father-son）保留策略：按天/周/月/年分桶，
+// 每个桶内保留最新的N份，叠加KeepLast份无条件保留最近备份。字段为0表示不在该档位额外保留。
+// MaxTotalSizeGB非0时，在分代保留之后再按从旧到新删除，直到本地备份总大小不超过该上限
+type RetentionConfig struct {
+	KeepLast       int     `json:"keep_last"`
+	KeepDaily      int     `json:"keep_daily"`
+	KeepWeekly     int     `json:"keep_weekly"`
+	KeepMonthly    int     `json:"keep_monthly"`
+	KeepYearly     int     `json:"keep_yearly"`
+	MaxTotalSizeGB float64 `json:"max_total_size_gb"`
 }
 
 type PluginConfig struct {
 	Compression CompressionConfig `json:"Compression"`
 	MaxWorkers  int               `json:"max_workers"`
 	Restore     RestoreConfig
+	Backup      BackupConfig `json:"Backup"`
 }
 
 // RestoreInfo 结构体
@@ -72,17 +154,31 @@ type RestoreInfo struct {
 // 全局变量
 var (
 	globalConfig GlobalConfig
-	pluginConfig PluginConfig
-	restoreInfo  RestoreInfo
-	logger       *log.Logger
-	logFile      *os.File
-	cyan         = color.New(color.FgCyan).SprintFunc()
-	white        = color.New(color.FgWhite).SprintFunc()
-	yellow       = color.New(color.FgYellow).SprintFunc()
-	red          = color.New(color.FgRed).SprintFunc()
-	green        = color.New(color.FgGreen).SprintFunc()
+	// pluginConfig只应该在pluginConfigMu保护下访问：loadConfig整个重建出新配置后
+	// 一次性把它换上去，而不是逐字段原地修改，读者统一走currentConfig()拿到的是
+	// 一份在该时刻完整一致的快照，不会看到"换了一半"的配置。这在daemon模式下是必须的——
+	// SIGHUP/配置文件变化触发的reload goroutine可能和cron调度的backupCurrentWorld
+	// 并发执行
+	pluginConfig   PluginConfig
+	pluginConfigMu sync.RWMutex
+	restoreInfo    RestoreInfo
+	logger         *log.Logger
+	logFile        *os.File
+	cyan           = color.New(color.FgCyan).SprintFunc()
+	white          = color.New(color.FgWhite).SprintFunc()
+	yellow         = color.New(color.FgYellow).SprintFunc()
+	red            = color.New(color.FgRed).SprintFunc()
+	green          = color.New(color.FgGreen).SprintFunc()
 )
 
+// currentConfig返回pluginConfig当前的一份快照（值拷贝），并发读者应该用它而不是
+// 直接读pluginConfig，尤其是在daemon模式下可能与reload并发执行的代码路径中
+func currentConfig() PluginConfig {
+	pluginConfigMu.RLock()
+	defer pluginConfigMu.RUnlock()
+	return pluginConfig
+}
+
 // pluginPrint 自定义日志输出
 func pluginPrint(text string, level string) {
 	// 如果是DEBUG级别且未开启DEBUG模式，则不输出
@@ -156,26 +252,32 @@ func setupLogging(serverDir string) error {
 	return nil
 }
 
-// loadConfig 加载配置文件
-func loadConfig(serverDir string) error {
-	// 尝试多个可能的配置文件路径
+// resolveConfigPath在几个约定的路径里找出实际存在的配置文件，一个都不存在时返回空字符串。
+// loadConfig和daemon模式下的watchConfigFile共用这份探测逻辑，保证重载监听的是同一个文件
+func resolveConfigPath(serverDir string) string {
 	possiblePaths := []string{
 		filepath.Join(serverDir, "plugins", "EasyBackuper", "config", "EasyBackuper.json"),
 		filepath.Join(".", "plugins", "EasyBackuper", "config", "EasyBackuper.json"),
-		filepath.Join(".", "plugins", "EasyBackuper", "config", "EasyBackuper.json"),
 	}
 
-	var configPath string
 	for _, path := range possiblePaths {
 		if _, err := os.Stat(path); err == nil {
-			configPath = path
-			break
+			return path
 		}
 	}
+	return ""
+}
+
+// loadConfig 加载配置文件。每次调用都会先把默认值和解析出的文件内容填进一个局部变量cfg，
+// 只有在整个cfg完全就绪之后才在pluginConfigMu的保护下一次性把它换上全局的pluginConfig，
+// 而不是逐字段原地改写全局变量。因此对同一份配置文件重复调用是幂等的，daemon模式下
+// SIGHUP/文件变化触发的reload可以放心复用，并发读者不会看到"换了一半"的配置
+func loadConfig(serverDir string) error {
+	configPath := resolveConfigPath(serverDir)
 
 	if configPath == "" {
 		pluginPrint("所有可能的配置文件路径都不存在，使用默认配置", "WARNING")
-		pluginConfig = PluginConfig{
+		cfg := PluginConfig{
 			Compression: CompressionConfig{
 				Method:    "zip",
 				Exe7zPath: "./plugins/EasyBackuper/7za.exe",
@@ -184,21 +286,26 @@ func loadConfig(serverDir string) error {
 			MaxWorkers: defaultMaxWorkers,
 		}
 		// 初始化默认格式
-		pluginConfig.Compression.Formats["7z"] = CompressionFormat{
+		cfg.Compression.Formats["7z"] = CompressionFormat{
 			Extension:    ".7z",
 			CompressArgs: []string{"a", "-t7z", "-mx=5"},
 			ExtractArgs:  []string{"x", "-y"},
 		}
-		pluginConfig.Compression.Formats["zip"] = CompressionFormat{
+		cfg.Compression.Formats["zip"] = CompressionFormat{
 			Extension:    ".zip",
 			CompressArgs: []string{"a", "-tzip", "-mx=5"},
 			ExtractArgs:  []string{"x", "-y"},
 		}
-		pluginConfig.Compression.Formats["tar"] = CompressionFormat{
+		cfg.Compression.Formats["tar"] = CompressionFormat{
 			Extension:    ".tar.gz",
 			CompressArgs: []string{"a", "-ttar", "-mx=5"},
 			ExtractArgs:  []string{"x", "-y"},
 		}
+		cfg.Backup.Mode = backupModeFull
+		cfg.Backup.Verify = verifyNone
+		pluginConfigMu.Lock()
+		pluginConfig = cfg
+		pluginConfigMu.Unlock()
 		return nil
 	}
 
@@ -217,7 +324,7 @@ func loadConfig(serverDir string) error {
 	}
 
 	// 初始化默认配置
-	pluginConfig = PluginConfig{
+	cfg := PluginConfig{
 		Compression: CompressionConfig{
 			Method:    "zip",
 			Exe7zPath: "./plugins/EasyBackuper/7za.exe",
@@ -226,17 +333,17 @@ func loadConfig(serverDir string) error {
 		MaxWorkers: defaultMaxWorkers,
 	}
 	// 初始化默认格式
-	pluginConfig.Compression.Formats["7z"] = CompressionFormat{
+	cfg.Compression.Formats["7z"] = CompressionFormat{
 		Extension:    ".7z",
 		CompressArgs: []string{"a", "-t7z", "-mx=5"},
 		ExtractArgs:  []string{"x", "-y"},
 	}
-	pluginConfig.Compression.Formats["zip"] = CompressionFormat{
+	cfg.Compression.Formats["zip"] = CompressionFormat{
 		Extension:    ".zip",
 		CompressArgs: []string{"a", "-tzip", "-mx=5"},
 		ExtractArgs:  []string{"x", "-y"},
 	}
-	pluginConfig.Compression.Formats["tar"] = CompressionFormat{
+	cfg.Compression.Formats["tar"] = CompressionFormat{
 		Extension:    ".tar.gz",
 		CompressArgs: []string{"a", "-ttar", "-mx=5"},
 		ExtractArgs:  []string{"x", "-y"},
@@ -245,14 +352,23 @@ func loadConfig(serverDir string) error {
 	// 设置插件配置
 	if compressionData, ok := config["Compression"].(map[string]interface{}); ok {
 		if method, ok := compressionData["method"].(string); ok {
-			pluginConfig.Compression.Method = method
+			cfg.Compression.Method = method
 		}
 		if exe7zPath, ok := compressionData["exe_7z_path"].(string); ok {
-			pluginConfig.Compression.Exe7zPath = exe7zPath
+			cfg.Compression.Exe7zPath = exe7zPath
+		}
+		if useExternal7z, ok := compressionData["use_external_7z"].(bool); ok {
+			cfg.Compression.UseExternal7z = useExternal7z
+		}
+		if level, ok := compressionData["level"].(float64); ok {
+			cfg.Compression.Level = int(level)
+		}
+		if threads, ok := compressionData["threads"].(float64); ok {
+			cfg.Compression.Threads = int(threads)
 		}
 		// 确保Formats map已初始化
-		if pluginConfig.Compression.Formats == nil {
-			pluginConfig.Compression.Formats = make(map[string]CompressionFormat)
+		if cfg.Compression.Formats == nil {
+			cfg.Compression.Formats = make(map[string]CompressionFormat)
 		}
 		if formatsData, ok := compressionData["formats"].(map[string]interface{}); ok {
 			for formatName, formatData := range formatsData {
@@ -275,15 +391,24 @@ func loadConfig(serverDir string) error {
 							}
 						}
 					}
-					pluginConfig.Compression.Formats[formatName] = format
+					if backend, ok := formatMap["backend"].(string); ok {
+						format.Backend = backend
+					}
+					if level, ok := formatMap["level"].(float64); ok {
+						format.Level = int(level)
+					}
+					if numCPU, ok := formatMap["num_cpu"].(float64); ok {
+						format.NumCPU = int(numCPU)
+					}
+					cfg.Compression.Formats[formatName] = format
 				}
 			}
 		}
 	}
 	if maxWorkers, ok := config["max_workers"].(float64); ok {
-		pluginConfig.MaxWorkers = int(maxWorkers)
+		cfg.MaxWorkers = int(maxWorkers)
 	} else {
-		pluginConfig.MaxWorkers = defaultMaxWorkers
+		cfg.MaxWorkers = defaultMaxWorkers
 	}
 
 	// 解析Restore配置
@@ -293,23 +418,168 @@ func loadConfig(serverDir string) error {
 				globalConfig.Debug = debugVal
 			}
 			if backupOldWorld, ok := configData["backup_old_world_before_restore"].(bool); ok {
-				pluginConfig.Restore.Config.BackupOldWorldBeforeRestore = backupOldWorld
+				cfg.Restore.Config.BackupOldWorldBeforeRestore = backupOldWorld
+			}
+			if rollbackKeepCount, ok := configData["rollback_keep_count"].(float64); ok {
+				cfg.Restore.Config.RollbackKeepCount = int(rollbackKeepCount)
 			}
 			if restartServer, ok := configData["restart_server"].(map[string]interface{}); ok {
 				if status, ok := restartServer["status"].(bool); ok {
-					pluginConfig.Restore.Config.RestartServer.Status = status
+					cfg.Restore.Config.RestartServer.Status = status
 				}
 				if waitTime, ok := restartServer["wait_time_s"].(float64); ok {
-					pluginConfig.Restore.Config.RestartServer.WaitTimeS = int(waitTime)
+					cfg.Restore.Config.RestartServer.WaitTimeS = int(waitTime)
 				}
 				if scriptPath, ok := restartServer["start_script_path"].(string); ok {
-					pluginConfig.Restore.Config.RestartServer.StartScriptPath = scriptPath
+					cfg.Restore.Config.RestartServer.StartScriptPath = scriptPath
+				}
+				if stopCommand, ok := restartServer["stop_command"].(string); ok {
+					cfg.Restore.Config.RestartServer.StopCommand = stopCommand
+				}
+				if stopTimeout, ok := restartServer["stop_timeout_s"].(float64); ok {
+					cfg.Restore.Config.RestartServer.StopTimeoutS = int(stopTimeout)
+				}
+				if healthCheckTimeout, ok := restartServer["health_check_timeout_s"].(float64); ok {
+					cfg.Restore.Config.RestartServer.HealthCheckTimeoutS = int(healthCheckTimeout)
+				}
+				if rconData, ok := restartServer["rcon"].(map[string]interface{}); ok {
+					if host, ok := rconData["host"].(string); ok {
+						cfg.Restore.Config.RestartServer.RCON.Host = host
+					}
+					if port, ok := rconData["port"].(float64); ok {
+						cfg.Restore.Config.RestartServer.RCON.Port = int(port)
+					}
+					if password, ok := rconData["password"].(string); ok {
+						cfg.Restore.Config.RestartServer.RCON.Password = password
+					}
+				}
+			}
+		}
+		if notificationsData, ok := restoreData["Notifications"].(map[string]interface{}); ok {
+			if url, ok := notificationsData["url"].(string); ok {
+				cfg.Restore.Notifications.URL = url
+			}
+			if secret, ok := notificationsData["secret"].(string); ok {
+				cfg.Restore.Notifications.Secret = secret
+			}
+			if attempts, ok := notificationsData["attempts"].(float64); ok {
+				cfg.Restore.Notifications.Attempts = int(attempts)
+			}
+			if backoffS, ok := notificationsData["backoff_s"].(float64); ok {
+				cfg.Restore.Notifications.BackoffS = int(backoffS)
+			}
+			if timeoutS, ok := notificationsData["timeout_s"].(float64); ok {
+				cfg.Restore.Notifications.TimeoutS = int(timeoutS)
+			}
+		}
+	}
+
+	// 解析Backup配置
+	cfg.Backup.Mode = backupModeFull
+	if backupData, ok := config["Backup"].(map[string]interface{}); ok {
+		if mode, ok := backupData["mode"].(string); ok {
+			cfg.Backup.Mode = mode
+		}
+		if retentionCount, ok := backupData["retention_count"].(float64); ok {
+			cfg.Backup.RetentionCount = int(retentionCount)
+		}
+		if verify, ok := backupData["verify"].(string); ok {
+			cfg.Backup.Verify = verify
+		}
+		if storageData, ok := backupData["storage"].(map[string]interface{}); ok {
+			if storageType, ok := storageData["type"].(string); ok {
+				cfg.Backup.Storage.Type = storageType
+			}
+			if s3Data, ok := storageData["s3"].(map[string]interface{}); ok {
+				if region, ok := s3Data["region"].(string); ok {
+					cfg.Backup.Storage.S3.Region = region
+				}
+				if endpoint, ok := s3Data["endpoint"].(string); ok {
+					cfg.Backup.Storage.S3.Endpoint = endpoint
+				}
+				if bucket, ok := s3Data["bucket"].(string); ok {
+					cfg.Backup.Storage.S3.Bucket = bucket
+				}
+				if accessKeyID, ok := s3Data["access_key_id"].(string); ok {
+					cfg.Backup.Storage.S3.AccessKeyID = accessKeyID
+				}
+				if secretAccessKey, ok := s3Data["secret_access_key"].(string); ok {
+					cfg.Backup.Storage.S3.SecretAccessKey = secretAccessKey
+				}
+				if usePathStyle, ok := s3Data["use_path_style"].(bool); ok {
+					cfg.Backup.Storage.S3.UsePathStyle = usePathStyle
+				}
+			}
+			if webdavData, ok := storageData["webdav"].(map[string]interface{}); ok {
+				if url, ok := webdavData["url"].(string); ok {
+					cfg.Backup.Storage.WebDAV.URL = url
+				}
+				if username, ok := webdavData["username"].(string); ok {
+					cfg.Backup.Storage.WebDAV.Username = username
+				}
+				if password, ok := webdavData["password"].(string); ok {
+					cfg.Backup.Storage.WebDAV.Password = password
+				}
+			}
+			if sftpData, ok := storageData["sftp"].(map[string]interface{}); ok {
+				if host, ok := sftpData["host"].(string); ok {
+					cfg.Backup.Storage.SFTP.Host = host
+				}
+				if port, ok := sftpData["port"].(float64); ok {
+					cfg.Backup.Storage.SFTP.Port = int(port)
+				}
+				if user, ok := sftpData["user"].(string); ok {
+					cfg.Backup.Storage.SFTP.User = user
+				}
+				if password, ok := sftpData["password"].(string); ok {
+					cfg.Backup.Storage.SFTP.Password = password
+				}
+				if privateKeyPath, ok := sftpData["private_key_path"].(string); ok {
+					cfg.Backup.Storage.SFTP.PrivateKeyPath = privateKeyPath
+				}
+			}
+			if httpData, ok := storageData["http"].(map[string]interface{}); ok {
+				if username, ok := httpData["username"].(string); ok {
+					cfg.Backup.Storage.HTTP.Username = username
+				}
+				if password, ok := httpData["password"].(string); ok {
+					cfg.Backup.Storage.HTTP.Password = password
+				}
+				if bearerToken, ok := httpData["bearer_token"].(string); ok {
+					cfg.Backup.Storage.HTTP.BearerToken = bearerToken
 				}
 			}
 		}
+		if retentionData, ok := backupData["retention"].(map[string]interface{}); ok {
+			if keepLast, ok := retentionData["keep_last"].(float64); ok {
+				cfg.Backup.Retention.KeepLast = int(keepLast)
+			}
+			if keepDaily, ok := retentionData["keep_daily"].(float64); ok {
+				cfg.Backup.Retention.KeepDaily = int(keepDaily)
+			}
+			if keepWeekly, ok := retentionData["keep_weekly"].(float64); ok {
+				cfg.Backup.Retention.KeepWeekly = int(keepWeekly)
+			}
+			if keepMonthly, ok := retentionData["keep_monthly"].(float64); ok {
+				cfg.Backup.Retention.KeepMonthly = int(keepMonthly)
+			}
+			if keepYearly, ok := retentionData["keep_yearly"].(float64); ok {
+				cfg.Backup.Retention.KeepYearly = int(keepYearly)
+			}
+			if maxTotalSizeGB, ok := retentionData["max_total_size_gb"].(float64); ok {
+				cfg.Backup.Retention.MaxTotalSizeGB = maxTotalSizeGB
+			}
+		}
+	}
+	if cfg.Backup.Verify == "" {
+		cfg.Backup.Verify = verifyNone
 	}
 
-	globalConfig.MaxWorkers = pluginConfig.MaxWorkers
+	globalConfig.MaxWorkers = cfg.MaxWorkers
+
+	pluginConfigMu.Lock()
+	pluginConfig = cfg
+	pluginConfigMu.Unlock()
 
 	pluginPrint(fmt.Sprintf("成功加载配置文件: %s", configPath), "SUCCESS")
 	pluginPrint(fmt.Sprintf("DEBUG模式: %v", globalConfig.Debug), "INFO")
@@ -318,8 +588,13 @@ func loadConfig(serverDir string) error {
 	return nil
 }
 
-// copyFileWithProgress 复制文件
-func copyFileWithProgress(src, dst string) error {
+// copyFileWithProgress 复制文件，doneBytes是多个文件共享的已完成字节计数器，
+// 用于在copyDirWithProgress中汇总出整个目录的进度
+func copyFileWithProgress(ctx context.Context, src, dst string, doneBytes *int64, totalBytes int64, progress Progress) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	pluginPrint(fmt.Sprintf("复制文件: %s --> %s", src, dst), "DEBUG")
 
 	sourceFile, err := os.Open(src)
@@ -340,7 +615,8 @@ func copyFileWithProgress(src, dst string) error {
 	}
 	defer destinationFile.Close()
 
-	_, err = io.Copy(destinationFile, sourceFile)
+	reader := &countingReader{r: sourceFile, done: doneBytes, total: totalBytes, path: src, progress: progress}
+	_, err = io.Copy(destinationFile, reader)
 	if err != nil {
 		return fmt.Errorf("复制文件内容失败: %v", err)
 	}
@@ -354,8 +630,9 @@ func copyFileWithProgress(src, dst string) error {
 	return nil
 }
 
-// copyDirWithProgress 多goroutine复制目录
-func copyDirWithProgress(src, dst string, maxThreads int) error {
+// copyDirWithProgress 多goroutine复制目录，接受ctx以便在SIGINT/SIGTERM时提前取消尚未开始的拷贝任务，
+// 并通过progress按总字节数上报复制进度
+func copyDirWithProgress(ctx context.Context, src, dst string, maxThreads int, progress Progress) error {
 	if _, err := os.Stat(dst); os.IsNotExist(err) {
 		if err := os.MkdirAll(dst, 0755); err != nil {
 			return fmt.Errorf("创建目标目录失败: %v", err)
@@ -366,6 +643,7 @@ func copyDirWithProgress(src, dst string, maxThreads int) error {
 	// 收集所有文件
 	var files []string
 	var dirs []string
+	var totalBytes int64
 
 	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -376,6 +654,7 @@ func copyDirWithProgress(src, dst string, maxThreads int) error {
 			dirs = append(dirs, path)
 		} else {
 			files = append(files, path)
+			totalBytes += info.Size()
 		}
 		return nil
 	})
@@ -408,6 +687,7 @@ func copyDirWithProgress(src, dst string, maxThreads int) error {
 	tasks := make(chan copyTask, len(files))
 	errors := make(chan error, len(files))
 	var wg sync.WaitGroup
+	var doneBytes int64
 
 	// 启动worker
 	for i := 0; i < maxThreads; i++ {
@@ -415,7 +695,7 @@ func copyDirWithProgress(src, dst string, maxThreads int) error {
 		go func() {
 			defer wg.Done()
 			for task := range tasks {
-				if err := copyFileWithProgress(task.src, task.dst); err != nil {
+				if err := copyFileWithProgress(ctx, task.src, task.dst, &doneBytes, totalBytes, progress); err != nil {
 					errors <- err
 				}
 			}
@@ -445,181 +725,6 @@ func copyDirWithProgress(src, dst string, maxThreads int) error {
 	}
 }
 
-// extractWith7z 使用7z解压
-func extractWith7z(archivePath, destDir string) error {
-	pluginPrint(fmt.Sprintf("使用7z解压: %s", archivePath), "INFO")
-	pluginPrint(fmt.Sprintf("解压目标: %s --> %s", archivePath, destDir), "INFO")
-
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command(pluginConfig.Compression.Exe7zPath, "x", archivePath, "-o"+destDir, "-y")
-	} else {
-		cmd = exec.Command("7z", "x", archivePath, "-o"+destDir, "-y")
-	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("7z解压失败: %v\n输出: %s", err, string(output))
-	}
-
-	pluginPrint("7z解压完成", "SUCCESS")
-	return nil
-}
-
-// extractWithTarGz 使用tar解压
-func extractWithTarGz(archivePath, destDir string) error {
-	pluginPrint(fmt.Sprintf("使用tar解压: %s", archivePath), "INFO")
-	pluginPrint(fmt.Sprintf("解压目标: %s --> %s", archivePath, destDir), "INFO")
-
-	file, err := os.Open(archivePath)
-	if err != nil {
-		return fmt.Errorf("打开压缩文件失败: %v", err)
-	}
-	defer file.Close()
-
-	gzReader, err := gzip.NewReader(file)
-	if err != nil {
-		return fmt.Errorf("创建gzip读取器失败: %v", err)
-	}
-	defer gzReader.Close()
-
-	tarReader := tar.NewReader(gzReader)
-
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("读取tar头部失败: %v", err)
-		}
-
-		targetPath := filepath.Join(destDir, header.Name)
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(targetPath, 0755); err != nil {
-				return fmt.Errorf("创建目录失败: %v", err)
-			}
-		case tar.TypeReg:
-			// 创建目录
-			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-				return fmt.Errorf("创建文件目录失败: %v", err)
-			}
-
-			// 创建文件
-			outFile, err := os.Create(targetPath)
-			if err != nil {
-				return fmt.Errorf("创建文件失败: %v", err)
-			}
-
-			if _, err := io.Copy(outFile, tarReader); err != nil {
-				outFile.Close()
-				return fmt.Errorf("写入文件失败: %v", err)
-			}
-			outFile.Close()
-
-			// 设置文件权限
-			if err := os.Chmod(targetPath, os.FileMode(header.Mode)); err != nil {
-				return fmt.Errorf("设置文件权限失败: %v", err)
-			}
-		}
-	}
-
-	pluginPrint("tar解压完成", "SUCCESS")
-	return nil
-}
-
-// compressWith7z 使用7z压缩
-func compressWith7z(srcDir, destFile string) error {
-	pluginPrint(fmt.Sprintf("使用7z压缩: %s", srcDir), "INFO")
-	pluginPrint(fmt.Sprintf("压缩目标: %s --> %s", srcDir, destFile), "INFO")
-
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command(pluginConfig.Compression.Exe7zPath, "a", destFile, srcDir+string(filepath.Separator)+"*", "-y")
-	} else {
-		cmd = exec.Command("7z", "a", destFile, srcDir+string(filepath.Separator)+"*", "-y")
-	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("7z压缩失败: %v\n输出: %s", err, string(output))
-	}
-
-	pluginPrint("7z压缩完成", "SUCCESS")
-	pluginPrint(fmt.Sprintf("备份文件已保存: %s", destFile), "SUCCESS")
-	return nil
-}
-
-// compressWithTarGz 使用tar压缩
-func compressWithTarGz(srcDir, destFile string) error {
-	pluginPrint(fmt.Sprintf("使用tar压缩: %s", srcDir), "INFO")
-	pluginPrint(fmt.Sprintf("压缩目标: %s --> %s", srcDir, destFile), "INFO")
-
-	file, err := os.Create(destFile)
-	if err != nil {
-		return fmt.Errorf("创建压缩文件失败: %v", err)
-	}
-	defer file.Close()
-
-	gzWriter := gzip.NewWriter(file)
-	defer gzWriter.Close()
-
-	tarWriter := tar.NewWriter(gzWriter)
-	defer tarWriter.Close()
-
-	baseDir := filepath.Dir(srcDir)
-	dirName := filepath.Base(srcDir)
-
-	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// 创建tar头部
-		header, err := tar.FileInfoHeader(info, "")
-		if err != nil {
-			return err
-		}
-
-		// 调整路径
-		relPath, err := filepath.Rel(baseDir, path)
-		if err != nil {
-			return err
-		}
-		header.Name = filepath.Join(dirName, relPath)
-
-		// 写入头部
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return err
-		}
-
-		// 如果是文件，写入内容
-		if !info.IsDir() {
-			file, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-
-			if _, err := io.Copy(tarWriter, file); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("压缩过程中发生错误: %v", err)
-	}
-
-	pluginPrint("tar压缩完成", "SUCCESS")
-	pluginPrint(fmt.Sprintf("备份文件已保存: %s", destFile), "SUCCESS")
-	return nil
-}
-
 // isProcessRunning 检测进程是否在运行
 func isProcessRunning(processName string) bool {
 	processes, err := ps.Processes()
@@ -636,17 +741,6 @@ func isProcessRunning(processName string) bool {
 	return false
 }
 
-// waitForProcessExit 等待进程退出
-func waitForProcessExit(processName string) {
-	pluginPrint(fmt.Sprintf("检测到%s进程正在运行，等待服务器关闭", processName), "WARNING")
-
-	for isProcessRunning(processName) {
-		time.Sleep(1 * time.Second)
-	}
-
-	pluginPrint("服务器已关闭", "SUCCESS")
-}
-
 // removeDir 删除目录
 func removeDir(dir string) error {
 	pluginPrint(fmt.Sprintf("正在删除目录: %s", dir), "INFO")
@@ -679,10 +773,15 @@ func removeDir(dir string) error {
 	return nil
 }
 
-// backupCurrentWorld 备份当前世界
-func backupCurrentWorld() error {
+// backupCurrentWorld 备份当前世界，接受ctx以便在收到中断信号时尽快放弃未完成的拷贝/压缩
+func backupCurrentWorld(ctx context.Context) error {
 	pluginPrint("配置为回档前自动备份当前世界", "INFO")
 
+	// 一次性取一份配置快照，下面全程只读这份快照，不再反复读全局pluginConfig——
+	// daemon模式下本函数由cron调度goroutine调用，可能跟SIGHUP/配置文件变化
+	// 触发的reload并发执行，反复读全局变量会撞上reload正在替换它的那一刻
+	cfg := currentConfig()
+
 	// 获取当前时间作为备份名称的一部分
 	currentTime := time.Now().Format("20060102_150405")
 	backupName := fmt.Sprintf("before_restore_%s", currentTime)
@@ -710,20 +809,20 @@ func backupCurrentWorld() error {
 
 	pluginPrint(fmt.Sprintf("正在备份当前世界: %s", worldsDir), "INFO")
 
-	if err := copyDirWithProgress(worldsDir, tempWorldBackupDir, globalConfig.MaxWorkers); err != nil {
+	if err := copyDirWithProgress(ctx, worldsDir, tempWorldBackupDir, globalConfig.MaxWorkers, newThrottledProgress()); err != nil {
 		return fmt.Errorf("备份世界目录失败: %v", err)
 	}
 
 	// 根据配置选择压缩方式
 	var oldBackupFilePath string
-	compressionMethod := pluginConfig.Compression.Method
+	compressionMethod := cfg.Compression.Method
 	if compressionMethod == "" {
 		compressionMethod = "zip" // 默认使用zip
 	}
 
 	// 获取文件扩展名
 	var fileExtension string
-	if format, ok := pluginConfig.Compression.Formats[compressionMethod]; ok {
+	if format, ok := cfg.Compression.Formats[compressionMethod]; ok {
 		fileExtension = format.Extension
 	} else {
 		fileExtension = ".zip" // 默认扩展名
@@ -731,27 +830,155 @@ func backupCurrentWorld() error {
 
 	oldBackupFilePath = filepath.Join(backupDir, backupName+fileExtension)
 
-	// 根据压缩方法选择压缩函数
-	switch compressionMethod {
-	case "7z", "zip":
-		if err := compressWith7z(tempWorldBackupDir, oldBackupFilePath); err != nil {
-			return err
-		}
-	case "tar":
-		if err := compressWithTarGz(tempWorldBackupDir, oldBackupFilePath); err != nil {
-			return err
+	snapshot, err := snapshotWorldDir(tempWorldBackupDir)
+	if err != nil {
+		return fmt.Errorf("计算世界文件指纹失败: %v", err)
+	}
+
+	backupMode := cfg.Backup.Mode
+	if backupMode == "" {
+		backupMode = backupModeFull
+	}
+
+	var parentBackupID string
+	if backupMode == backupModeIncremental {
+		parentPath, parentManifest, err := latestBackupForWorld(backupDir, restoreInfo.WorldName)
+		if err != nil {
+			pluginPrint(fmt.Sprintf("查找父备份失败，回退为全量备份: %v", err), "WARNING")
+			backupMode = backupModeFull
+		} else if parentManifest == nil {
+			pluginPrint("未找到可用的父备份，本次按全量备份执行", "INFO")
+			backupMode = backupModeFull
+		} else if retentionCount := cfg.Backup.RetentionCount; retentionCount > 0 {
+			if depth, err := incrementalChainDepth(backupDir, parentManifest); err != nil {
+				pluginPrint(fmt.Sprintf("计算增量链长度失败，回退为全量备份: %v", err), "WARNING")
+				backupMode = backupModeFull
+			} else if depth+1 >= retentionCount {
+				pluginPrint(fmt.Sprintf("增量链长度(%d)已达到retention_count(%d)，本次强制执行全量备份", depth+1, retentionCount), "INFO")
+				backupMode = backupModeFull
+			}
 		}
-	default:
-		// 默认使用7z压缩
-		if err := compressWith7z(tempWorldBackupDir, oldBackupFilePath); err != nil {
-			return err
+
+		if backupMode == backupModeIncremental {
+			changes := computeChangeset(snapshot, parentManifest)
+			patchPath := filepath.Join(backupDir, backupName) + patchExtension
+			whiteouts, err := writePatchLayer(ctx, tempWorldBackupDir, patchPath, changes)
+			if err != nil {
+				return fmt.Errorf("写入增量补丁失败: %v", err)
+			}
+
+			parentBackupID = filepath.Base(parentPath)
+			pluginPrint(fmt.Sprintf("增量备份完成，变更 %d 项，父备份: %s", len(changes), parentBackupID), "SUCCESS")
+
+			archiveHash, err := hashFile(patchPath)
+			if err != nil {
+				return fmt.Errorf("计算补丁层哈希失败: %v", err)
+			}
+
+			patchInfo, err := os.Stat(patchPath)
+			if err != nil {
+				return fmt.Errorf("读取补丁层文件信息失败: %v", err)
+			}
+			if err := writeArchiveMetadata(patchPath, &ArchiveMetadata{
+				OriginalSize:   totalFileSize(snapshot),
+				CompressedSize: patchInfo.Size(),
+				Method:         compressionMethod,
+				CreatedAt:      time.Now(),
+				WorldName:      restoreInfo.WorldName,
+				SHA256:         archiveHash,
+			}); err != nil {
+				return err
+			}
+
+			manifest := &BackupManifest{
+				BackupID:       backupName,
+				ParentBackupID: parentBackupID,
+				WorldName:      restoreInfo.WorldName,
+				Mode:           backupModeIncremental,
+				CreatedAt:      time.Now(),
+				Files:          snapshot,
+				ArchiveHash:    archiveHash,
+			}
+			if err := writeManifest(filepath.Join(backupDir, backupName), manifest, whiteouts); err != nil {
+				return err
+			}
+			if err := uploadBackupArtifacts(ctx, patchPath, patchPath+metadataSuffix,
+				filepath.Join(backupDir, backupName)+manifestSuffix, filepath.Join(backupDir, backupName)+".whiteouts.json"); err != nil {
+				pluginPrint(fmt.Sprintf("推送增量备份到远程存储失败: %v", err), "WARNING")
+			}
+			if err := pruneOldBackups(backupDir, restoreInfo.WorldName, cfg.Backup.Retention); err != nil {
+				pluginPrint(fmt.Sprintf("执行保留策略失败: %v", err), "WARNING")
+			}
+			return nil
 		}
 	}
 
+	archiver := newArchiver(externalExe7zPath(cfg.Compression))
+	if err := archiver.Compress(ctx, tempWorldBackupDir, oldBackupFilePath, newThrottledProgress()); err != nil {
+		return err
+	}
+
+	archiveHash, err := hashFile(oldBackupFilePath)
+	if err != nil {
+		return fmt.Errorf("计算备份归档哈希失败: %v", err)
+	}
+
+	archiveInfo, err := os.Stat(oldBackupFilePath)
+	if err != nil {
+		return fmt.Errorf("读取备份归档文件信息失败: %v", err)
+	}
+	if err := writeArchiveMetadata(oldBackupFilePath, &ArchiveMetadata{
+		OriginalSize:   totalFileSize(snapshot),
+		CompressedSize: archiveInfo.Size(),
+		Method:         compressionMethod,
+		CreatedAt:      time.Now(),
+		WorldName:      restoreInfo.WorldName,
+		SHA256:         archiveHash,
+	}); err != nil {
+		return err
+	}
+
+	manifest := &BackupManifest{
+		BackupID:    backupName,
+		WorldName:   restoreInfo.WorldName,
+		Mode:        backupModeFull,
+		CreatedAt:   time.Now(),
+		Files:       snapshot,
+		ArchiveHash: archiveHash,
+	}
+	if err := writeManifest(filepath.Join(backupDir, backupName), manifest, nil); err != nil {
+		return err
+	}
+
+	if err := uploadBackupArtifacts(ctx, oldBackupFilePath, oldBackupFilePath+metadataSuffix,
+		filepath.Join(backupDir, backupName)+manifestSuffix); err != nil {
+		pluginPrint(fmt.Sprintf("推送备份到远程存储失败: %v", err), "WARNING")
+	}
+
+	if err := pruneOldBackups(backupDir, restoreInfo.WorldName, cfg.Backup.Retention); err != nil {
+		pluginPrint(fmt.Sprintf("执行保留策略失败: %v", err), "WARNING")
+	}
+
 	pluginPrint("回档前备份完成", "SUCCESS")
 	return nil
 }
 
+// externalExe7zPath 仅在用户显式开启use_external_7z时返回非空路径，
+// 否则压缩/解压一律走内置的纯Go归档实现。compression由调用方传入而不是在这里
+// 直接读全局pluginConfig，这样backupCurrentWorld可以传入它在入口处一次性取好的
+// 配置快照，不会在cron调度的备份跑到一半时跟并发的配置reload撞车
+func externalExe7zPath(compression CompressionConfig) string {
+	if !compression.UseExternal7z {
+		return ""
+	}
+
+	if compression.Exe7zPath != "" {
+		return compression.Exe7zPath
+	}
+
+	return "7z"
+}
+
 // restartServer 重启服务器
 func restartServer() {
 	restartConfig := pluginConfig.Restore.Config.RestartServer
@@ -771,7 +998,11 @@ func restartServer() {
 
 	startScriptPath := restartConfig.StartScriptPath
 	if startScriptPath == "" {
-		startScriptPath = "./start.bat"
+		if runtime.GOOS == "windows" {
+			startScriptPath = "./start.bat"
+		} else {
+			startScriptPath = "./start.sh"
+		}
 	}
 
 	pluginPrint(fmt.Sprintf("启动脚本路径: %s", startScriptPath), "INFO")
@@ -787,53 +1018,76 @@ func restartServer() {
 	pluginPrint(fmt.Sprintf("服务器目录: %s", restoreInfo.ServerDir), "INFO")
 	pluginPrint(fmt.Sprintf("启动脚本完整路径: %s", startScriptFullPath), "INFO")
 
-	// 执行启动脚本
-	pluginPrint("正在启动服务器...", "INFO")
-
 	// 检查脚本文件是否存在
 	if _, err := os.Stat(startScriptFullPath); os.IsNotExist(err) {
 		pluginPrint(fmt.Sprintf("启动脚本不存在: %s", startScriptFullPath), "ERROR")
 		return
 	}
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		// Windows 上使用 start 命令打开新窗口执行批处理文件
-		cmd_path := os.Getenv("PATH")
-		pluginPrint(cmd_path, "INFO")
-		cmd = exec.Command("C:\\Windows\\System32\\cmd.exe", "/c", "start", "/I", startScriptFullPath)
-	} else {
-		// Linux/Mac 上直接执行脚本文件
-		// cmd = exec.Command(startScriptFullPath)
-		// 暂不支持Linux/Mac
-		pluginPrint("暂不支持Linux/Mac", "ERROR")
-		pluginPrint("请手动启动服务器", "INFO")
+	// 正在启动服务器：具体方式由平台相关的startServerProcessPlatform实现
+	// （Linux/macOS下会创建stdin具名管道以支持之后的stop_command）
+	pluginPrint("正在启动服务器...", "INFO")
+
+	pid, err := startServerProcessPlatform(restoreInfo.ServerDir, startScriptFullPath, logFile)
+	if err != nil {
+		pluginPrint(fmt.Sprintf("启动服务器失败: %v", err), "ERROR")
 		return
 	}
 
-	// 设置工作目录
-	cmd.Dir = restoreInfo.ServerDir
-
-	// 打印命令信息用于调试
-	pluginPrint(fmt.Sprintf("执行命令: %s", cmd.String()), "INFO")
-	pluginPrint(fmt.Sprintf("工作目录: %s", cmd.Dir), "INFO")
-
-	// 执行命令并等待完成
-	if err := cmd.Run(); err != nil {
-		pluginPrint(fmt.Sprintf("启动服务器失败: %v", err), "ERROR")
-	} else {
-		pluginPrint("服务器启动命令已执行", "SUCCESS")
+	if err := writePIDFile(pidFilePath(restoreInfo.ServerDir), pid); err != nil {
+		pluginPrint(fmt.Sprintf("写入pid文件失败: %v", err), "WARNING")
 	}
+
+	pluginPrint(fmt.Sprintf("服务器启动命令已执行，PID %d", pid), "SUCCESS")
 }
 
 // main 主函数
 func main() {
 	// 解析命令行参数
-	backupFile := flag.String("backup", "", "备份文件路径")
+	backupFile := flag.String("backup", "", "备份文件路径，支持本地路径，或s3://bucket/key、webdav://path、sftp://user@host/path、https://host/path形式的远程URI")
 	serverDir := flag.String("server", "", "服务器目录")
 	worldName := flag.String("world", "", "世界名称")
+	onlyGlob := flag.String("only", "", "只恢复匹配该glob模式的相对路径（例如 db/* 或某个维度目录），省略则恢复全部，仅对分块zstd归档能省去解压未匹配文件的开销")
+	verifyOnly := flag.Bool("verify", false, "只核实-backup指定归档的metadata sidecar（大小+sha256）是否完好，不解压也不回档")
+	daemonMode := flag.Bool("daemon", false, "以长期运行模式启动：监听SIGHUP和配置文件变化以热重载配置，配合-schedule可周期性执行世界备份，不回档")
+	schedule := flag.String("schedule", "", "仅daemon模式下生效，cron表达式（如 0 3 * * *），用于周期性执行世界备份，留空则daemon只负责热重载配置")
 	flag.Parse()
 
+	// daemon模式不回档，只需要-server来定位配置文件和世界目录
+	if *daemonMode {
+		if *serverDir == "" {
+			fmt.Println("使用方法: easybackuper -daemon -server <服务器目录> [-schedule <cron表达式>]")
+			fmt.Println("缺少必要的参数")
+			os.Exit(1)
+		}
+		if err := setupLogging(*serverDir); err != nil {
+			fmt.Printf("设置日志失败: %v\n", err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		restoreInfo = RestoreInfo{ServerDir: *serverDir}
+		if err := os.Chdir(*serverDir); err != nil {
+			pluginPrint(fmt.Sprintf("切换工作目录失败: %v", err), "ERROR")
+			os.Exit(1)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigCh
+			pluginPrint("收到中断信号，daemon正在退出...", "WARNING")
+			cancel()
+		}()
+
+		if err := runDaemon(ctx, *serverDir, *schedule); err != nil {
+			pluginPrint(fmt.Sprintf("daemon模式异常退出: %v", err), "ERROR")
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 检查必要参数
 	if *backupFile == "" || *serverDir == "" || *worldName == "" {
 		fmt.Println("使用方法: easybackuper -backup <备份文件> -server <服务器目录> -world <世界名称>")
@@ -872,6 +1126,45 @@ func main() {
 	pluginPrint(fmt.Sprintf("工作目录: %s", restoreInfo.ServerDir), "INFO")
 	pluginPrint(strings.Repeat("=", 60), "INFO")
 
+	// 补发上次进程退出时遗留在本地队列里、还没能送达外部面板的回档状态通知
+	flushNotificationQueue(restoreInfo.ServerDir, pluginConfig.Restore.Notifications)
+
+	// -verify只核实归档完整性，不解压、不触碰服务器进程或世界目录
+	if *verifyOnly {
+		pluginPrint(fmt.Sprintf("开始核实归档 %s 的metadata sidecar", restoreInfo.BackupFile), "INFO")
+		if err := verifyArchiveMetadataFile(restoreInfo.BackupFile); err != nil {
+			pluginPrint(fmt.Sprintf("归档完整性核实失败: %v", err), "ERROR")
+			os.Exit(1)
+		}
+		pluginPrint("归档完整性核实通过", "SUCCESS")
+		return
+	}
+
+	// tempDir在创建后才会被赋值为非空，cleanup只在那之后才实际删除目录；
+	// backupCurrentWorld正常返回时由其自身的defer清理它的临时目录，cleanup只管解压用的tempDir。
+	// 提前声明是为了让下面的信号处理goroutine能捕获到之后赋值的路径
+	var tempDir string
+	cleanup := func() {
+		if tempDir != "" {
+			os.RemoveAll(tempDir)
+		}
+	}
+
+	// 安装SIGINT/SIGTERM信号处理，收到信号后取消ctx并清理临时目录，
+	// 避免半途而废的回档在temp_easybackuper/temp_easybackuper_backup下留下垃圾
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		pluginPrint("收到中断信号，正在取消当前操作并清理临时目录...", "WARNING")
+		cancel()
+		cleanup()
+		// os.Exit不会执行backupCurrentWorld里的defer，它的临时目录在这里一并删除
+		os.RemoveAll(filepath.Join(restoreInfo.ServerDir, "temp_easybackuper_backup"))
+		os.Exit(130)
+	}()
+
 	// 切换工作目录
 	if err := os.Chdir(restoreInfo.ServerDir); err != nil {
 		pluginPrint(fmt.Sprintf("切换工作目录失败: %v", err), "ERROR")
@@ -887,8 +1180,13 @@ func main() {
 		processName = "bedrock_server"
 	}
 
-	if isProcessRunning(processName) {
-		waitForProcessExit(processName)
+	if isServerRunning(restoreInfo.ServerDir, processName) {
+		restartConfig := pluginConfig.Restore.Config.RestartServer
+		pluginPrint("尝试请求服务器优雅关闭", "INFO")
+		if err := sendStopCommand(restoreInfo.ServerDir, restartConfig); err != nil {
+			pluginPrint(fmt.Sprintf("发送停机请求失败，回退为等待超时后强制终止: %v", err), "WARNING")
+		}
+		waitForProcessExit(restoreInfo.ServerDir, processName, restartConfig.StopTimeoutS)
 	} else {
 		pluginPrint(fmt.Sprintf("未检测到%s进程，继续回档操作", processName), "INFO")
 	}
@@ -897,7 +1195,7 @@ func main() {
 
 	// 检查是否需要在回档前备份当前世界
 	if pluginConfig.Restore.Config.BackupOldWorldBeforeRestore {
-		if err := backupCurrentWorld(); err != nil {
+		if err := backupCurrentWorld(ctx); err != nil {
 			pluginPrint(fmt.Sprintf("回档前备份失败: %v", err), "ERROR")
 			// 继续执行，不终止
 		}
@@ -908,11 +1206,15 @@ func main() {
 	pluginPrint(strings.Repeat("=", 60), "INFO")
 
 	// 恢复备份
+	restoreStartTime := time.Now()
 	pluginPrint("开始恢复备份", "INFO")
+	if *onlyGlob != "" {
+		pluginPrint(fmt.Sprintf("启用-only子集恢复，匹配模式: %s", *onlyGlob), "INFO")
+	}
 	worldsDir := filepath.Join(restoreInfo.ServerDir, "worlds")
 
 	// 创建临时目录用于解压
-	tempDir := filepath.Join(restoreInfo.ServerDir, "temp_easybackuper")
+	tempDir = filepath.Join(restoreInfo.ServerDir, "temp_easybackuper")
 	if _, err := os.Stat(tempDir); err == nil {
 		os.RemoveAll(tempDir)
 	}
@@ -921,86 +1223,232 @@ func main() {
 		pluginPrint(fmt.Sprintf("创建临时目录失败: %v", err), "ERROR")
 		os.Exit(1)
 	}
-	defer os.RemoveAll(tempDir)
 
 	pluginPrint(fmt.Sprintf("创建临时目录: %s", tempDir), "INFO")
 
-	// 根据配置选择解压方式
+	// 解压：不再按扩展名分支选择7z/tar，统一交给归档器按文件头嗅探格式；
+	// 如果该备份是增量链中的一层，则先回溯父备份链再逐层应用补丁
 	tempWorldDir := filepath.Join(tempDir, restoreInfo.WorldName)
 	backupFilePath := restoreInfo.BackupFile
+	backupIDPath := backupIDPathFromFile(backupFilePath)
 
-	var err error
-	// 根据文件扩展名选择解压方式
-	if strings.HasSuffix(strings.ToLower(backupFilePath), ".7z") {
-		pluginPrint("检测到.7z格式备份文件，使用7z解压", "INFO")
-		err = extractWith7z(backupFilePath, tempWorldDir)
-	} else if strings.HasSuffix(strings.ToLower(backupFilePath), ".zip") {
-		pluginPrint("检测到.zip格式备份文件，使用7z解压", "INFO")
-		err = extractWith7z(backupFilePath, tempWorldDir)
-	} else if strings.HasSuffix(strings.ToLower(backupFilePath), ".tar.gz") || strings.HasSuffix(strings.ToLower(backupFilePath), ".tgz") {
-		pluginPrint("检测到.tar.gz格式备份文件，使用tar解压", "INFO")
-		err = extractWithTarGz(backupFilePath, tempWorldDir)
-		// 如果tar解压失败，尝试使用7z解压
-		if err != nil {
-			pluginPrint("tar解压失败，尝试使用7z解压", "WARNING")
-			err = extractWith7z(backupFilePath, tempWorldDir)
+	manifest, err := readManifest(backupIDPath)
+	if err != nil {
+		pluginPrint(fmt.Sprintf("读取备份manifest失败: %v", err), "ERROR")
+		cleanup()
+		os.Exit(1)
+	}
+
+	verifyLevel := pluginConfig.Backup.Verify
+	if verifyLevel == "" {
+		verifyLevel = verifyNone
+	}
+
+	if verifyLevel != verifyNone {
+		if manifest == nil {
+			pluginPrint("该备份没有manifest（可能产自旧版本），跳过完整性校验", "WARNING")
+		} else {
+			pluginPrint(fmt.Sprintf("开始校验备份完整性（级别: %s）", verifyLevel), "INFO")
+			if err := verifyArchiveChain(ctx, backupFilePath, backupIDPath, manifest); err != nil {
+				pluginPrint(fmt.Sprintf("备份完整性校验失败，已中止回档: %v", err), "ERROR")
+				cleanup()
+				os.Exit(1)
+			}
+			pluginPrint("备份归档完整性校验通过", "SUCCESS")
+		}
+	}
+
+	if manifest != nil && manifest.Mode == backupModeIncremental {
+		pluginPrint(fmt.Sprintf("检测到增量备份 %s，开始回溯父备份链", manifest.BackupID), "INFO")
+		if err := applyPatchChain(ctx, backupIDPath, manifest, tempWorldDir, *onlyGlob); err != nil {
+			pluginPrint(fmt.Sprintf("应用增量备份链失败: %v", err), "ERROR")
+			cleanup()
+			os.Exit(1)
 		}
 	} else {
-		// 默认使用配置中的设置
-		compressionMethod := pluginConfig.Compression.Method
-		if compressionMethod == "" {
-			compressionMethod = "zip" // 默认使用zip
+		archiver := newArchiver(externalExe7zPath(currentConfig().Compression))
+		if err := archiver.Extract(ctx, backupFilePath, tempWorldDir, *onlyGlob, newThrottledProgress()); err != nil {
+			pluginPrint(fmt.Sprintf("解压失败: %v", err), "ERROR")
+			cleanup()
+			os.Exit(1)
 		}
+	}
 
-		switch compressionMethod {
-		case "7z", "zip":
-			pluginPrint("使用配置中的7z解压", "INFO")
-			err = extractWith7z(backupFilePath, tempWorldDir)
-		case "tar":
-			pluginPrint("使用配置中的tar解压", "INFO")
-			err = extractWithTarGz(backupFilePath, tempWorldDir)
-		default:
-			// 默认使用7z解压
-			pluginPrint("使用默认的7z解压", "INFO")
-			err = extractWith7z(backupFilePath, tempWorldDir)
+	if verifyLevel == verifyPerFile && manifest != nil {
+		if *onlyGlob != "" {
+			pluginPrint("启用了-only子集恢复，跳过per-file完整性校验（manifest记录的是完整文件树）", "WARNING")
+		} else {
+			pluginPrint("开始逐个校验解压后的文件哈希", "INFO")
+			if err := verifyExtractedFiles(tempWorldDir, manifest.Files); err != nil {
+				pluginPrint(fmt.Sprintf("解压文件完整性校验失败，已中止回档: %v", err), "ERROR")
+				cleanup()
+				os.Exit(1)
+			}
+			pluginPrint("解压文件完整性校验通过", "SUCCESS")
 		}
 	}
 
-	if err != nil {
-		pluginPrint(fmt.Sprintf("解压失败: %v", err), "ERROR")
-		os.Exit(1)
+	// -only子集恢复本来就只落盘了部分文件树，不具备完整世界的结构，跳过该检查；
+	// 否则在冲掉当前世界目录之前，先确认解压结果像一份完整的世界存档，而不是
+	// 半棵被截断/损坏归档产出的残缺文件树
+	if *onlyGlob == "" {
+		pluginPrint("开始核实解压出的世界目录结构", "INFO")
+		if err := verifyRestoredWorld(tempWorldDir); err != nil {
+			pluginPrint(fmt.Sprintf("解压出的世界目录未通过结构校验，已中止回档，当前世界目录未被触碰: %v", err), "ERROR")
+			cleanup()
+			os.Exit(1)
+		}
+		pluginPrint("世界目录结构校验通过", "SUCCESS")
 	}
 
 	pluginPrint(strings.Repeat("=", 60), "INFO")
 
-	// 删除现有的世界目录
 	currentWorldDir := filepath.Join(worldsDir, restoreInfo.WorldName)
+	worldWasPresent := false
 	if _, err := os.Stat(currentWorldDir); err == nil {
-		if err := removeDir(currentWorldDir); err != nil {
-			pluginPrint(fmt.Sprintf("删除旧世界目录失败: %v", err), "ERROR")
-			// 继续执行
+		worldWasPresent = true
+	}
+
+	var bytesRestored int64
+	var rollbackDir string
+
+	if *onlyGlob != "" {
+		// -only只解压出了匹配到的那部分文件树，不是一份完整世界，不能像下面"整目录原子互换"
+		// 那样直接顶替当前世界目录——否则未匹配到的文件（比如没被-only选中的维度目录）会被
+		// 整个冲掉、换成空。这里改为把解压出的子集逐个文件合并覆盖到当前世界目录里：
+		// 只有匹配到的文件被改写，其余文件保持原样不动。
+		// 代价是这种就地合并没有整体回滚能力——没有保存被覆盖文件的旧版本，后面健康检查
+		// 失败时只能提示用户手动核实，不能像整目录互换那样一键换回旧目录
+		pluginPrint("启用了-only子集恢复，原地合并覆盖匹配到的文件，不做整目录替换", "INFO")
+		pluginPrint(fmt.Sprintf("合并目标: %s ==> %s", tempWorldDir, currentWorldDir), "INFO")
+		pluginPrint(fmt.Sprintf("使用 %d 个goroutine进行文件复制", globalConfig.MaxWorkers), "INFO")
+
+		if err := copyDirWithProgress(ctx, tempWorldDir, currentWorldDir, globalConfig.MaxWorkers, newThrottledProgress()); err != nil {
+			pluginPrint(fmt.Sprintf("合并子集文件失败: %v", err), "ERROR")
+			sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+				World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusFailed,
+				DurationMs: time.Since(restoreStartTime).Milliseconds(), Error: err.Error(),
+			})
+			cleanup()
+			os.Exit(1)
+		}
+
+		bytesRestored, _ = dirSize(tempWorldDir)
+		pluginPrint("子集文件合并完成", "SUCCESS")
+	} else {
+		// 先把解压出的新世界复制到worlds目录下的一个兄弟暂存目录，不直接碰当前世界目录，
+		// 这样任何一步失败都不会留下半成品：当前世界要么完全未动，要么已经被原子rename换走
+		swapTimestamp := time.Now().Format("20060102_150405")
+		stagingDir := stagingWorldDir(worldsDir, restoreInfo.WorldName, swapTimestamp)
+		rollbackDir = rollbackWorldDirFor(worldsDir, restoreInfo.WorldName, swapTimestamp)
+
+		pluginPrint("开始复制文件...", "INFO")
+		pluginPrint(fmt.Sprintf("复制目标: %s ==> %s", tempWorldDir, stagingDir), "INFO")
+		pluginPrint(fmt.Sprintf("使用 %d 个goroutine进行文件复制", globalConfig.MaxWorkers), "INFO")
+
+		if err := copyDirWithProgress(ctx, tempWorldDir, stagingDir, globalConfig.MaxWorkers, newThrottledProgress()); err != nil {
+			pluginPrint(fmt.Sprintf("复制到暂存目录失败: %v", err), "ERROR")
+			removeDir(stagingDir)
+			sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+				World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusFailed,
+				DurationMs: time.Since(restoreStartTime).Milliseconds(), Error: err.Error(),
+			})
+			cleanup()
+			os.Exit(1)
+		}
+
+		bytesRestored, _ = dirSize(stagingDir)
+
+		pluginPrint("文件复制完成", "SUCCESS")
+		pluginPrint(strings.Repeat("=", 60), "INFO")
+
+		// 把当前世界目录挪到回滚目录，再把暂存目录换到当前世界目录的位置；
+		// worldWasPresent记录当前世界目录原本是否存在，决定失败时是否需要换回
+		if worldWasPresent {
+			if err := os.Rename(currentWorldDir, rollbackDir); err != nil {
+				pluginPrint(fmt.Sprintf("把当前世界目录换到回滚目录失败，已中止回档: %v", err), "ERROR")
+				removeDir(stagingDir)
+				sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+					World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusFailed,
+					DurationMs: time.Since(restoreStartTime).Milliseconds(), BytesRestored: bytesRestored, Error: err.Error(),
+				})
+				cleanup()
+				os.Exit(1)
+			}
+		}
+
+		if err := os.Rename(stagingDir, currentWorldDir); err != nil {
+			pluginPrint(fmt.Sprintf("切换新世界目录失败，正在换回回滚目录: %v", err), "ERROR")
+			if worldWasPresent {
+				if rbErr := rollbackWorld(rollbackDir, currentWorldDir); rbErr != nil {
+					pluginPrint(fmt.Sprintf("换回回滚目录失败: %v", rbErr), "ERROR")
+				}
+			}
+			sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+				World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusFailed,
+				DurationMs: time.Since(restoreStartTime).Milliseconds(), BytesRestored: bytesRestored, Error: err.Error(),
+			})
+			cleanup()
+			os.Exit(1)
 		}
 	}
 
+	pluginPrint("备份恢复完成", "SUCCESS")
 	pluginPrint(strings.Repeat("=", 60), "INFO")
 
-	// 复制文件从临时目录到目标目录
-	pluginPrint("开始复制文件...", "INFO")
-	pluginPrint(fmt.Sprintf("复制目标: %s ==> %s", tempWorldDir, worldsDir), "INFO")
-	pluginPrint(fmt.Sprintf("使用 %d 个goroutine进行文件复制", globalConfig.MaxWorkers), "INFO")
+	// 世界目录已经换位成功，在重启服务器前先发一次"已恢复"状态通知；
+	// 重启结果（成功/失败）由下面健康检查之后的第二次通知上报
+	sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+		World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusRestored,
+		DurationMs: time.Since(restoreStartTime).Milliseconds(), BytesRestored: bytesRestored,
+	})
 
-	if err := copyDirWithProgress(tempWorldDir, worldsDir, globalConfig.MaxWorkers); err != nil {
-		pluginPrint(fmt.Sprintf("文件复制失败: %v", err), "ERROR")
+	// 重启服务器，并在健康检查窗口内确认进程真的起来了；没起来就当作本次回档失败，
+	// 自动把回滚目录换回当前世界目录的位置，而不是留下一个服务器起不来的新世界
+	restartServer()
+	restartConfig := pluginConfig.Restore.Config.RestartServer
+	if restartConfig.Status && !waitForServerHealthy(restoreInfo.ServerDir, processName, restartConfig.HealthCheckTimeoutS) {
+		rollbackErr := ""
+		if *onlyGlob != "" {
+			// 就地合并没有保存被覆盖文件的旧版本，没法像整目录互换那样一键换回，
+			// 只能如实告知，留给用户自己核实/从其它备份手动恢复
+			pluginPrint("服务器未能在健康检查窗口内恢复运行；由于本次是-only子集原地合并恢复，无法自动回滚，请手动核实世界目录", "ERROR")
+		} else {
+			pluginPrint("服务器未能在健康检查窗口内恢复运行，正在回滚世界目录", "ERROR")
+			if err := removeDir(currentWorldDir); err != nil {
+				pluginPrint(fmt.Sprintf("删除未能启动服务器的新世界目录失败: %v", err), "ERROR")
+			}
+			if worldWasPresent {
+				if err := rollbackWorld(rollbackDir, currentWorldDir); err != nil {
+					pluginPrint(fmt.Sprintf("回滚世界目录失败: %v", err), "ERROR")
+					rollbackErr = err.Error()
+				} else {
+					pluginPrint("已回滚到回档前的世界目录，请手动检查服务器状态", "WARNING")
+				}
+			}
+		}
+		sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+			World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusFailed,
+			DurationMs: time.Since(restoreStartTime).Milliseconds(), BytesRestored: bytesRestored,
+			Error: strings.TrimSpace("服务器未能在健康检查窗口内恢复运行 " + rollbackErr),
+		})
+		cancel()
+		cleanup()
 		os.Exit(1)
 	}
 
-	pluginPrint("文件复制完成", "SUCCESS")
-	pluginPrint(strings.Repeat("=", 60), "INFO")
+	if worldWasPresent && *onlyGlob == "" {
+		if err := pruneRollbackDirs(worldsDir, restoreInfo.WorldName, pluginConfig.Restore.Config.RollbackKeepCount); err != nil {
+			pluginPrint(fmt.Sprintf("清理历史回滚目录失败: %v", err), "WARNING")
+		}
+	}
 
-	pluginPrint("备份恢复完成", "SUCCESS")
-	pluginPrint(strings.Repeat("=", 60), "INFO")
+	sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+		World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusSuccess,
+		DurationMs: time.Since(restoreStartTime).Milliseconds(), BytesRestored: bytesRestored,
+	})
 
-	// 重启服务器
-	restartServer()
+	cancel()
+	cleanup()
 	os.Exit(0)
 }
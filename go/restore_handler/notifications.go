@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// NotificationConfig 配置回档结束时向外部面板/机器人发送的HTTP回调钩子，
+// 例如Pterodactyl风格的面板、Discord机器人或Prometheus Alertmanager receiver，
+// 用于把服务器标记为"已恢复/已激活"（类似wings的SendRestorationStatus）
+type NotificationConfig struct {
+	URL string `json:"url"`
+	// Secret非空时，请求体会用HMAC-SHA256签名，签名结果放在X-Signature请求头，
+	// 接收端可以据此校验请求确实来自本插件
+	Secret string `json:"secret"`
+	// Attempts是发送失败时的最大尝试次数（含首次），为0时默认3次
+	Attempts int `json:"attempts"`
+	// BackoffS是相邻两次尝试之间的等待秒数，为0时默认5秒
+	BackoffS int `json:"backoff_s"`
+	// TimeoutS是单次HTTP请求的超时时间（秒），为0时默认10秒
+	TimeoutS int `json:"timeout_s"`
+}
+
+// NotificationEvent 是发往外部面板的回档状态回调的请求体
+type NotificationEvent struct {
+	World         string `json:"world"`
+	BackupFile    string `json:"backup_file"`
+	Status        string `json:"status"` // restored / success / failed
+	DurationMs    int64  `json:"duration_ms"`
+	BytesRestored int64  `json:"bytes_restored"`
+	Error         string `json:"error,omitempty"`
+}
+
+const (
+	notifyStatusRestored = "restored"
+	notifyStatusSuccess  = "success"
+	notifyStatusFailed   = "failed"
+)
+
+// notificationQueueFileName是未送达的通知事件持久化的位置，与pid文件同目录，
+// 每行一个JSON事件；下次进程启动时flushNotificationQueue会重新尝试发送
+const notificationQueueFileName = "notification_queue.jsonl"
+
+func notificationQueuePath(serverDir string) string {
+	return filepath.Join(serverDir, "logs", pluginName, notificationQueueFileName)
+}
+
+// sendRestoreNotification 按配置的重试策略尝试把回档状态回调发给外部面板，
+// URL为空时视为未启用该功能直接跳过。重试全部耗尽后把事件持久化到本地队列文件，
+// 发送失败不会中止回档/重启流程——调用方不需要关心通知是否真的送达。
+// 发送是同步的：重试期间（含每次请求超时和重试间隔）调用方会一直等待
+func sendRestoreNotification(serverDir string, cfg NotificationConfig, event NotificationEvent) {
+	if cfg.URL == "" {
+		return
+	}
+
+	if err := deliverNotification(cfg, event); err != nil {
+		pluginPrint(fmt.Sprintf("发送回档状态通知失败，已加入本地队列待下次启动时重试: %v", err), "WARNING")
+		if qerr := enqueueNotification(serverDir, event); qerr != nil {
+			pluginPrint(fmt.Sprintf("持久化未送达的通知事件失败: %v", qerr), "WARNING")
+		}
+	}
+}
+
+// deliverNotification 对单个事件执行一次HTTP POST，按cfg.Attempts/cfg.BackoffS重试
+func deliverNotification(cfg NotificationConfig, event NotificationEvent) error {
+	attempts := cfg.Attempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	backoff := cfg.BackoffS
+	if backoff <= 0 {
+		backoff = 5
+	}
+	timeout := cfg.TimeoutS
+	if timeout <= 0 {
+		timeout = 10
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化通知事件失败: %v", err)
+	}
+
+	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(backoff) * time.Second)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, cfg.URL, bytes.NewReader(body))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if cfg.Secret != "" {
+			req.Header.Set("X-Signature", signNotificationBody(cfg.Secret, body))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("收到非2xx响应: %d", resp.StatusCode)
+	}
+
+	return lastErr
+}
+
+// signNotificationBody 对请求体计算HMAC-SHA256签名并以十六进制返回，
+// 接收端用同样的secret对请求体重算一遍即可校验请求确实来自本插件
+func signNotificationBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueueNotification 把未能送达的事件追加写入本地队列文件（每行一个JSON对象），
+// 即使进程退出，这些事件也能在下次启动时被flushNotificationQueue捞起来重试
+func enqueueNotification(serverDir string, event NotificationEvent) error {
+	path := notificationQueuePath(serverDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建通知队列目录失败: %v", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("序列化通知事件失败: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("打开通知队列文件失败: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入通知队列文件失败: %v", err)
+	}
+	return nil
+}
+
+// flushNotificationQueue 在进程启动时尝试重新发送上次遗留在队列里的通知事件。
+// 发送成功的从队列中移除，仍然失败的保留，整体重写队列文件
+func flushNotificationQueue(serverDir string, cfg NotificationConfig) {
+	if cfg.URL == "" {
+		return
+	}
+
+	path := notificationQueuePath(serverDir)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			pluginPrint(fmt.Sprintf("读取通知队列文件失败: %v", err), "WARNING")
+		}
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var remaining []string
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		var event NotificationEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			pluginPrint(fmt.Sprintf("解析队列中的通知事件失败，已丢弃: %v", err), "WARNING")
+			continue
+		}
+		if err := deliverNotification(cfg, event); err != nil {
+			remaining = append(remaining, line)
+			continue
+		}
+		pluginPrint(fmt.Sprintf("补发队列中积压的回档状态通知成功: %s", event.World), "INFO")
+	}
+
+	if len(remaining) == 0 {
+		os.Remove(path)
+		return
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(remaining, "\n")+"\n"), 0644); err != nil {
+		pluginPrint(fmt.Sprintf("重写通知队列文件失败: %v", err), "WARNING")
+	}
+}
+
+// dirSize 递归统计目录下所有文件的总字节数，用于在通知事件里上报bytes_restored；
+// 遍历失败时返回已累计的部分大小和错误，调用方可以选择忽略错误只用best-effort的值
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-ps"
+)
+
+const pidFileName = "bedrock_server.pid"
+
+// pidFilePath 返回本插件记录服务器进程PID的文件路径
+func pidFilePath(serverDir string) string {
+	return filepath.Join(serverDir, "logs", pluginName, pidFileName)
+}
+
+// writePIDFile 把进程PID写入pid文件，供下次回档时优先通过PID而不是按可执行文件名
+// 子串匹配来判断服务器是否还在运行
+func writePIDFile(path string, pid int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建pid文件目录失败: %v", err)
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(pid)), 0644)
+}
+
+// readPIDFile 读取pid文件；文件不存在或内容非法时返回0，调用方应回退到按进程名扫描
+func readPIDFile(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// removePIDFile 在确认进程已退出后清理pid文件，避免下次回档误判服务器仍在运行
+func removePIDFile(path string) {
+	os.Remove(path)
+}
+
+// isPIDRunning 按PID而不是可执行文件名子串判断进程是否存在，比旧的字符串匹配更可靠
+func isPIDRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := ps.FindProcess(pid)
+	if err != nil || process == nil {
+		return false
+	}
+	return true
+}
+
+// isServerRunning 优先通过pid文件判断服务器是否在运行；pid文件缺失或其记录的进程
+// 已不存在时，回退到isProcessRunning的按可执行文件名扫描
+func isServerRunning(serverDir, processName string) bool {
+	if pid := readPIDFile(pidFilePath(serverDir)); pid > 0 && isPIDRunning(pid) {
+		return true
+	}
+	return isProcessRunning(processName)
+}
+
+// waitForServerHealthy 在timeoutS内每秒轮询一次服务器进程是否已经起来，用于restartServer
+// 之后确认本次重启是否真的成功；timeoutS<=0时默认30秒。窗口结束时仍未检测到进程则返回false，
+// 调用方应据此判定本次回档失败并把回滚目录换回当前世界目录
+func waitForServerHealthy(serverDir, processName string, timeoutS int) bool {
+	if timeoutS <= 0 {
+		timeoutS = 30
+	}
+	deadline := time.Now().Add(time.Duration(timeoutS) * time.Second)
+	for time.Now().Before(deadline) {
+		if isServerRunning(serverDir, processName) {
+			return true
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return isServerRunning(serverDir, processName)
+}
+
+// sendStopCommand 尝试主动请求正在运行的服务器优雅关闭，而不是被动轮询等待。
+// 配置了RCON时优先使用RCON（不要求服务器是由本插件启动的），否则退回到
+// stop_command对应的平台相关实现（具名管道等），两者都未配置时什么也不做
+func sendStopCommand(serverDir string, restartConfig RestartServerConfig) error {
+	if restartConfig.RCON.Host != "" {
+		return sendRCONStopCommand(restartConfig.RCON.Host, restartConfig.RCON.Port, restartConfig.RCON.Password)
+	}
+	if restartConfig.StopCommand == "" {
+		return nil
+	}
+	return sendStopCommandPlatform(serverDir, restartConfig.StopCommand)
+}
+
+// pollUntilExit在timeout内每秒轮询一次pid是否还存在，超时前退出则返回true，
+// 超时后仍在运行则返回false（调用方决定是否升级为强制终止）
+func pollUntilExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !isPIDRunning(pid) {
+			return true
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return !isPIDRunning(pid)
+}
+
+// waitForProcessExit 等待服务器进程退出，优先轮询pid文件记录的PID，
+// pid文件缺失或记录的PID已不存在时回退到按进程名的旧逻辑。
+// stopTimeoutS大于0时，先给这么多秒的"优雅关闭"窗口，超时仍未退出则发送
+// 平台相关的强制终止信号（Unix下SIGTERM，Windows下CTRL_BREAK），再继续无限期轮询，
+// 避免sendStopCommand发出的请求没生效时永远卡在这里。这一超时升级只作用于按PID等待的分支，
+// 按进程名回退时不发送终止信号，只无限期轮询
+func waitForProcessExit(serverDir, processName string, stopTimeoutS int) {
+	pidPath := pidFilePath(serverDir)
+	pid := readPIDFile(pidPath)
+
+	if pid > 0 && isPIDRunning(pid) {
+		pluginPrint(fmt.Sprintf("检测到%s进程正在运行（PID %d），等待服务器关闭", processName, pid), "WARNING")
+
+		if stopTimeoutS > 0 {
+			if pollUntilExit(pid, time.Duration(stopTimeoutS)*time.Second) {
+				removePIDFile(pidPath)
+				pluginPrint("服务器已关闭", "SUCCESS")
+				return
+			}
+			pluginPrint(fmt.Sprintf("等待%d秒后服务器仍未关闭，发送强制终止信号", stopTimeoutS), "WARNING")
+			if err := terminateProcessPlatform(pid); err != nil {
+				pluginPrint(fmt.Sprintf("发送强制终止信号失败: %v", err), "WARNING")
+			}
+		}
+
+		for isPIDRunning(pid) {
+			time.Sleep(1 * time.Second)
+		}
+		removePIDFile(pidPath)
+		pluginPrint("服务器已关闭", "SUCCESS")
+		return
+	}
+
+	pluginPrint(fmt.Sprintf("检测到%s进程正在运行，等待服务器关闭", processName), "WARNING")
+	for isProcessRunning(processName) {
+		time.Sleep(1 * time.Second)
+	}
+	pluginPrint("服务器已关闭", "SUCCESS")
+}
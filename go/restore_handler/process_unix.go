@@ -0,0 +1,74 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// stopFIFOName是服务器stdin对应的具名管道文件名，和start脚本放在服务器目录下
+const stopFIFOName = "bedrock_server_stdin.fifo"
+
+// startServerProcessPlatform 在Linux/macOS上通过/bin/sh -c启动脚本，并放入独立会话
+// （Setsid），这样回档程序退出后子进程不会被一并杀死；标准输出/错误重定向到插件日志文件，
+// 标准输入接到一个具名管道上，供sendStopCommandPlatform日后写入停机命令
+func startServerProcessPlatform(serverDir, startScriptFullPath string, logFile *os.File) (int, error) {
+	fifoPath := filepath.Join(serverDir, stopFIFOName)
+	os.Remove(fifoPath)
+	if err := syscall.Mkfifo(fifoPath, 0600); err != nil {
+		return 0, fmt.Errorf("创建stdin具名管道失败: %v", err)
+	}
+
+	// 以读写模式打开FIFO的读端，避免纯只读方式在还没有写端连接时阻塞
+	stdin, err := os.OpenFile(fifoPath, os.O_RDWR, os.ModeNamedPipe)
+	if err != nil {
+		return 0, fmt.Errorf("打开stdin具名管道失败: %v", err)
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", startScriptFullPath)
+	cmd.Dir = serverDir
+	cmd.Stdin = stdin
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		stdin.Close()
+		return 0, fmt.Errorf("启动服务器进程失败: %v", err)
+	}
+
+	return cmd.Process.Pid, nil
+}
+
+// terminateProcessPlatform 向pid发送SIGTERM，用于优雅关闭超时后的强制终止
+func terminateProcessPlatform(pid int) error {
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("发送SIGTERM失败: %v", err)
+	}
+	return nil
+}
+
+// sendStopCommandPlatform 把停机命令写入服务器stdin对应的具名管道。
+// 要求服务器进程确实是由startServerProcessPlatform启动的，否则这个管道不存在
+func sendStopCommandPlatform(serverDir, stopCommand string) error {
+	fifoPath := filepath.Join(serverDir, stopFIFOName)
+	if _, err := os.Stat(fifoPath); err != nil {
+		return fmt.Errorf("未找到服务器stdin管道 %s（服务器可能不是由本插件启动）: %v", fifoPath, err)
+	}
+
+	f, err := os.OpenFile(fifoPath, os.O_WRONLY, os.ModeNamedPipe)
+	if err != nil {
+		return fmt.Errorf("打开服务器stdin管道失败: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(stopCommand + "\n"); err != nil {
+		return fmt.Errorf("写入停机命令失败: %v", err)
+	}
+
+	return nil
+}
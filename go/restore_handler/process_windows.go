@@ -0,0 +1,52 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// createNewProcessGroup对应Windows的CREATE_NEW_PROCESS_GROUP创建标志，
+// 使子进程脱离回档程序所在的进程组，不会在回档进程退出时被一并终止
+const createNewProcessGroup = 0x00000200
+
+// startServerProcessPlatform 在Windows上沿用cmd /c start打开新窗口启动脚本的方式，
+// 额外设置CREATE_NEW_PROCESS_GROUP，使服务器进程在回档程序退出后继续存活
+func startServerProcessPlatform(serverDir, startScriptFullPath string, logFile *os.File) (int, error) {
+	cmd := exec.Command("C:\\Windows\\System32\\cmd.exe", "/c", "start", "/I", startScriptFullPath)
+	cmd.Dir = serverDir
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: createNewProcessGroup}
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("启动服务器进程失败: %v", err)
+	}
+
+	return cmd.Process.Pid, nil
+}
+
+// sendStopCommandPlatform Windows下暂不支持通过具名管道发送停机命令
+func sendStopCommandPlatform(serverDir, stopCommand string) error {
+	return fmt.Errorf("Windows平台暂不支持stop_command，请手动停止服务器")
+}
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procGenerateConsoleCtrlEvent = kernel32.NewProc("GenerateConsoleCtrlEvent")
+)
+
+// ctrlBreakEvent对应CTRL_BREAK_EVENT，只能发给用CREATE_NEW_PROCESS_GROUP启动的进程组，
+// 这正是startServerProcessPlatform已经设置的创建标志
+const ctrlBreakEvent = 1
+
+// terminateProcessPlatform 通过GenerateConsoleCtrlEvent向pid所在的进程组发送CTRL_BREAK，
+// 用于优雅关闭超时后的强制终止
+func terminateProcessPlatform(pid int) error {
+	r, _, err := procGenerateConsoleCtrlEvent.Call(uintptr(ctrlBreakEvent), uintptr(pid))
+	if r == 0 {
+		return fmt.Errorf("GenerateConsoleCtrlEvent失败: %v", err)
+	}
+	return nil
+}
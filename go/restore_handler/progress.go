@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress 在长时间的复制/解压/压缩过程中上报字节级进度。
+// total<=0 表示调用方无法提前得知总量（例如边读取归档边嗅探格式），
+// 此时实现应退化为只展示已完成字节数而不计算百分比
+type Progress interface {
+	Update(done, total int64, currentPath string)
+}
+
+// throttledProgress 是Progress的默认实现，按时间间隔和百分比变化双重节流，
+// 避免大文件场景下每写入一个块就打印一行日志
+type throttledProgress struct {
+	mu           sync.Mutex
+	interval     time.Duration
+	minPctDelta  float64
+	lastReportAt time.Time
+	lastPct      float64
+}
+
+// newThrottledProgress 构造一个默认节流策略：至少间隔500ms，或百分比变化达到5%才上报一次
+func newThrottledProgress() *throttledProgress {
+	return &throttledProgress{
+		interval:    500 * time.Millisecond,
+		minPctDelta: 5,
+	}
+}
+
+func (p *throttledProgress) Update(done, total int64, currentPath string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	sinceLast := now.Sub(p.lastReportAt)
+
+	if total <= 0 {
+		if sinceLast < p.interval {
+			return
+		}
+		p.lastReportAt = now
+		pluginPrint(fmt.Sprintf("进度: 已处理 %d 字节 - %s", done, currentPath), "INFO")
+		return
+	}
+
+	pct := float64(done) / float64(total) * 100
+	isDone := done >= total
+	if sinceLast < p.interval && pct-p.lastPct < p.minPctDelta && !isDone {
+		return
+	}
+
+	p.lastReportAt = now
+	p.lastPct = pct
+	pluginPrint(fmt.Sprintf("进度: %.1f%% (%d/%d) - %s", pct, done, total, currentPath), "INFO")
+}
+
+// countingReader 包装io.Reader，每次Read都把增量字节数原子地累加进*done并上报给Progress。
+// done是指向调用方共享计数器的指针，多个countingReader可以在并发worker之间共享同一个计数器
+type countingReader struct {
+	r        io.Reader
+	done     *int64
+	total    int64
+	path     string
+	progress Progress
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		total := atomic.AddInt64(c.done, int64(n))
+		if c.progress != nil {
+			c.progress.Update(total, c.total, c.path)
+		}
+	}
+	return n, err
+}
+
+// countingWriter是countingReader的镜像，用来给压缩这种由第三方库内部驱动写入、
+// 我们拿不到逐文件回调的场景上报进度（上报的是写入压缩产物的字节数，而非原始大小）
+type countingWriter struct {
+	w        io.Writer
+	done     *int64
+	total    int64
+	path     string
+	progress Progress
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if n > 0 {
+		total := atomic.AddInt64(c.done, int64(n))
+		if c.progress != nil {
+			c.progress.Update(total, c.total, c.path)
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	rconTypeAuth        = 3
+	rconTypeExecCommand = 2
+	rconTimeout         = 5 * time.Second
+)
+
+// sendRCONStopCommand 通过Source RCON协议向host:port认证后发送"stop"命令，请求
+// bedrock_server优雅关闭。相比stop_command具名管道，这种方式不要求服务器进程是
+// 由本插件启动的，只要求bedrock_server自身开启了RCON
+func sendRCONStopCommand(host string, port int, password string) error {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), rconTimeout)
+	if err != nil {
+		return fmt.Errorf("连接RCON %s:%d 失败: %v", host, port, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(rconTimeout))
+
+	if err := writeRCONPacket(conn, 1, rconTypeAuth, password); err != nil {
+		return fmt.Errorf("发送RCON认证包失败: %v", err)
+	}
+	authReqID, err := readRCONPacket(conn)
+	if err != nil {
+		return fmt.Errorf("读取RCON认证响应失败: %v", err)
+	}
+	if authReqID == -1 {
+		return fmt.Errorf("RCON认证失败，请检查密码")
+	}
+
+	if err := writeRCONPacket(conn, 2, rconTypeExecCommand, "stop"); err != nil {
+		return fmt.Errorf("发送RCON stop命令失败: %v", err)
+	}
+	if _, err := readRCONPacket(conn); err != nil {
+		return fmt.Errorf("读取RCON命令响应失败: %v", err)
+	}
+	return nil
+}
+
+// writeRCONPacket按Source RCON的包格式写出：int32长度 + int32请求ID + int32类型 +
+// 负载 + 两个空字节终止符
+func writeRCONPacket(w io.Writer, requestID, packetType int32, payload string) error {
+	body := []byte(payload)
+	size := int32(4 + 4 + len(body) + 2)
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, size)
+	binary.Write(buf, binary.LittleEndian, requestID)
+	binary.Write(buf, binary.LittleEndian, packetType)
+	buf.Write(body)
+	buf.Write([]byte{0, 0})
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readRCONPacket读取一个RCON响应包并返回其请求ID（认证失败时为-1），丢弃负载内容，
+// 因为目前只关心stop命令是否被接受，不关心服务器的文字回复
+func readRCONPacket(r io.Reader) (int32, error) {
+	var size int32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return 0, err
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(body[0:4])), nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimestampPattern 匹配备份ID末尾的_YYYYMMDD_HHMMSS时间戳，
+// 既覆盖现有的before_restore_前缀，也覆盖daemon.go定时任务未来可能产出的其他前缀
+var backupTimestampPattern = regexp.MustCompile(`_(\d{8}_\d{6})$`)
+
+// parseBackupTimestamp 从备份ID（如before_restore_20260715_120000）中解析出文件名自带的时间戳。
+// pruneOldBackups按这个时间而不是manifest.CreatedAt分桶，这样即便manifest缺失或损坏也能判断新旧
+func parseBackupTimestamp(backupID string) (time.Time, error) {
+	m := backupTimestampPattern.FindStringSubmatch(backupID)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("无法从备份ID %s 中解析出时间戳", backupID)
+	}
+	return time.Parse("20060102_150405", m[1])
+}
+
+// backupRecord 汇总一份备份（全量归档或某一层增量补丁）参与保留策略决策所需的信息
+type backupRecord struct {
+	BackupID    string
+	Path        string
+	ArchivePath string
+	CreatedAt   time.Time
+	Manifest    *BackupManifest
+	Size        int64
+}
+
+// backupArchiveFilePath 返回某份备份实际的归档文件路径：增量层固定是.patch.tar.gz，
+// 全量备份则按约定的扩展名在同目录下查找
+func backupArchiveFilePath(backupIDPath string, manifest *BackupManifest) string {
+	if manifest.Mode == backupModeIncremental {
+		return backupIDPath + patchExtension
+	}
+	return resolveBackupArchivePath(backupIDPath)
+}
+
+// backupTotalSize 统计一份备份在本地磁盘上占用的总大小：归档本体加上metadata/manifest/whiteouts
+// 这几个sidecar（不存在的sidecar直接跳过），用于max_total_size_gb限额判断
+func backupTotalSize(backupIDPath, archivePath string) int64 {
+	var total int64
+	for _, p := range []string{archivePath, archivePath + metadataSuffix, backupIDPath + manifestSuffix, backupIDPath + ".whiteouts.json"} {
+		if info, err := os.Stat(p); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// listBackupsForWorld 枚举backupDir下某个世界的全部备份（全量+各层增量），供pruneOldBackups
+// 执行分代保留策略；与latestBackupForWorld不同，这里返回全部记录而非只取最新一份
+func listBackupsForWorld(backupDir, worldName string) ([]*backupRecord, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []*backupRecord
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), manifestSuffix) {
+			continue
+		}
+
+		backupID := strings.TrimSuffix(entry.Name(), manifestSuffix)
+		backupPath := filepath.Join(backupDir, backupID)
+		manifest, err := readManifest(backupPath)
+		if err != nil || manifest == nil || manifest.WorldName != worldName {
+			continue
+		}
+
+		createdAt, err := parseBackupTimestamp(backupID)
+		if err != nil {
+			// 文件名不符合约定格式（例如历史遗留的手动重命名），退回manifest里记录的时间
+			createdAt = manifest.CreatedAt
+		}
+
+		archivePath := backupArchiveFilePath(backupPath, manifest)
+		records = append(records, &backupRecord{
+			BackupID:    backupID,
+			Path:        backupPath,
+			ArchivePath: archivePath,
+			CreatedAt:   createdAt,
+			Manifest:    manifest,
+			Size:        backupTotalSize(backupPath, archivePath),
+		})
+	}
+	return records, nil
+}
+
+func dailyBucketKey(t time.Time) string   { return t.Format("2006-01-02") }
+func monthlyBucketKey(t time.Time) string { return t.Format("2006-01") }
+func yearlyBucketKey(t time.Time) string  { return t.Format("2006") }
+
+func weeklyBucketKey(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// keepNewestPerBucket 把records（必须已按CreatedAt从新到旧排序）按bucketKey分组，
+// 每组保留最新的n份，写入keep集合；n<=0表示该档位不启用
+func keepNewestPerBucket(records []*backupRecord, n int, bucketKey func(time.Time) string, keep map[string]bool) {
+	if n <= 0 {
+		return
+	}
+	count := make(map[string]int)
+	for _, r := range records {
+		k := bucketKey(r.CreatedAt)
+		if count[k] < n {
+			keep[r.BackupID] = true
+			count[k]++
+		}
+	}
+}
+
+// expandWithAncestors 为keep集合中的每份备份补上它沿ParentBackupID回溯到的所有祖先，
+// 否则删除某一层增量备份的父备份会导致保留下来的那份备份再也无法还原
+func expandWithAncestors(records []*backupRecord, keep map[string]bool) {
+	byID := make(map[string]*backupRecord, len(records))
+	for _, r := range records {
+		byID[r.BackupID] = r
+	}
+
+	ids := make([]string, 0, len(keep))
+	for id := range keep {
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		cur := byID[id]
+		for cur != nil && cur.Manifest.Mode == backupModeIncremental && cur.Manifest.ParentBackupID != "" {
+			parentID := cur.Manifest.ParentBackupID
+			if keep[parentID] {
+				break
+			}
+			keep[parentID] = true
+			cur = byID[parentID]
+		}
+	}
+}
+
+// pruneBySizeCap 在分代保留决策之后，若本地备份总大小仍超过capBytes，从旧到新继续踢出keep集合，
+// 直到降到上限以内。仍被其他保留备份依赖（作为增量链父备份）的条目会被跳过，避免破坏可恢复性，
+// 即便因此无法把总大小完全降到上限以下
+func pruneBySizeCap(records []*backupRecord, keep map[string]bool, capBytes int64) {
+	childCount := make(map[string]int)
+	var total int64
+	for _, r := range records {
+		if keep[r.BackupID] {
+			total += r.Size
+			if r.Manifest.Mode == backupModeIncremental {
+				childCount[r.Manifest.ParentBackupID]++
+			}
+		}
+	}
+
+	// records已按CreatedAt从新到旧排序，从末尾往前遍历即为从旧到新
+	for i := len(records) - 1; i >= 0 && total > capBytes; i-- {
+		r := records[i]
+		if !keep[r.BackupID] || childCount[r.BackupID] > 0 {
+			continue
+		}
+		keep[r.BackupID] = false
+		total -= r.Size
+		if r.Manifest.Mode == backupModeIncremental {
+			childCount[r.Manifest.ParentBackupID]--
+		}
+	}
+}
+
+// pruneOldBackups 在backupCurrentWorld写入新归档后执行分代（grandfather-father-son）保留策略：
+// 按备份文件名中的时间戳分桶到日/周/月/年，每个桶内保留最新的N份，再叠加keep_last份无条件保留
+// 最近的备份，其余的删除；若配置了max_total_size_gb，再按从旧到新删除直到本地总大小不超过上限。
+// 只处理本地backupDir下的文件，已推送到远程存储的历史副本不受影响
+func pruneOldBackups(backupDir, worldName string, policy RetentionConfig) error {
+	records, err := listBackupsForWorld(backupDir, worldName)
+	if err != nil {
+		return fmt.Errorf("列出历史备份失败: %v", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.After(records[j].CreatedAt) })
+
+	keep := make(map[string]bool)
+	for i, r := range records {
+		if i < policy.KeepLast {
+			keep[r.BackupID] = true
+		}
+	}
+	keepNewestPerBucket(records, policy.KeepDaily, dailyBucketKey, keep)
+	keepNewestPerBucket(records, policy.KeepWeekly, weeklyBucketKey, keep)
+	keepNewestPerBucket(records, policy.KeepMonthly, monthlyBucketKey, keep)
+	keepNewestPerBucket(records, policy.KeepYearly, yearlyBucketKey, keep)
+	expandWithAncestors(records, keep)
+
+	if policy.MaxTotalSizeGB > 0 {
+		pruneBySizeCap(records, keep, int64(policy.MaxTotalSizeGB*1024*1024*1024))
+	}
+
+	var keptNames, deletedNames []string
+	for _, r := range records {
+		if keep[r.BackupID] {
+			keptNames = append(keptNames, r.BackupID)
+			continue
+		}
+		if err := deleteBackupFiles(r.Path, r.ArchivePath); err != nil {
+			pluginPrint(fmt.Sprintf("删除过期备份 %s 失败: %v", r.BackupID, err), "WARNING")
+			continue
+		}
+		deletedNames = append(deletedNames, r.BackupID)
+	}
+
+	if len(deletedNames) > 0 {
+		pluginPrint(fmt.Sprintf("保留策略执行完毕，保留 %d 份备份（%s），删除 %d 份过期备份（%s）",
+			len(keptNames), strings.Join(keptNames, ", "), len(deletedNames), strings.Join(deletedNames, ", ")), "INFO")
+	} else {
+		pluginPrint(fmt.Sprintf("保留策略执行完毕，保留 %d 份备份，没有需要删除的过期备份", len(keptNames)), "INFO")
+	}
+	return nil
+}
+
+// deleteBackupFiles 删除一份备份的归档本体及其metadata/manifest/whiteouts sidecar，
+// 文件本就不存在时不算错误
+func deleteBackupFiles(backupIDPath, archivePath string) error {
+	for _, p := range []string{archivePath, archivePath + metadataSuffix, backupIDPath + manifestSuffix, backupIDPath + ".whiteouts.json"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除 %s 失败: %v", p, err)
+		}
+	}
+	return nil
+}
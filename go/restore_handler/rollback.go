@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// newWorldDirSuffix和rollbackWorldDirSuffix是回档原地换位使用的暂存/回滚兄弟目录命名约定，
+// 时间戳后缀保证同一个世界先后多次回档不会互相覆盖
+const (
+	newWorldDirSuffix      = ".new-"
+	rollbackWorldDirSuffix = ".rollback-"
+)
+
+// stagingWorldDir 返回本次回档用于落地解压/复制结果的暂存目录，
+// 是worlds目录下与世界同名、带时间戳后缀的兄弟目录
+func stagingWorldDir(worldsDir, worldName, timestamp string) string {
+	return filepath.Join(worldsDir, worldName+newWorldDirSuffix+timestamp)
+}
+
+// rollbackWorldDirFor 返回本次回档把当前世界目录挪走后存放的回滚目录，
+// 换位失败或重启健康检查超时时从这里把世界目录换回去
+func rollbackWorldDirFor(worldsDir, worldName, timestamp string) string {
+	return filepath.Join(worldsDir, worldName+rollbackWorldDirSuffix+timestamp)
+}
+
+// rollbackWorld 把回滚目录换回currentWorldDir所在位置，用于原地换位失败或重启健康检查
+// 超时后恢复到回档前的状态。currentWorldDir此时应是本次回档换上去的新世界目录
+func rollbackWorld(rollbackDir, currentWorldDir string) error {
+	if _, err := os.Stat(rollbackDir); err != nil {
+		return fmt.Errorf("回滚目录不存在: %v", err)
+	}
+	if _, err := os.Stat(currentWorldDir); err == nil {
+		if err := removeDir(currentWorldDir); err != nil {
+			return fmt.Errorf("删除失败的新世界目录失败: %v", err)
+		}
+	}
+	if err := os.Rename(rollbackDir, currentWorldDir); err != nil {
+		return fmt.Errorf("把回滚目录换回世界目录失败: %v", err)
+	}
+	return nil
+}
+
+// pruneRollbackDirs 清理worldsDir下某个世界积累的历史回滚目录，只保留最近keep份；
+// keep<=0表示不自动清理，交给用户手动处理
+func pruneRollbackDirs(worldsDir, worldName string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(worldsDir)
+	if err != nil {
+		return fmt.Errorf("遍历worlds目录失败: %v", err)
+	}
+
+	prefix := worldName + rollbackWorldDirSuffix
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
+			names = append(names, entry.Name())
+		}
+	}
+	// 目录名末尾是YYYYMMDD_HHMMSS时间戳，字典序排序就是时间先后顺序
+	sort.Strings(names)
+
+	if len(names) <= keep {
+		return nil
+	}
+
+	for _, name := range names[:len(names)-keep] {
+		path := filepath.Join(worldsDir, name)
+		if err := removeDir(path); err != nil {
+			return fmt.Errorf("删除历史回滚目录%s失败: %v", name, err)
+		}
+	}
+	return nil
+}
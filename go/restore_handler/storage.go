@@ -0,0 +1,651 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/pkg/sftp"
+	"github.com/studio-b12/gowebdav"
+	"golang.org/x/crypto/ssh"
+)
+
+// ObjectInfo 描述远程/本地存储中的一个备份产物条目，用于List()枚举
+type ObjectInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// StorageBackend 统一本地磁盘与远程对象/文件存储的读写接口，name是backend内部
+// 寻址用的相对路径（不含backend自身的bucket/目录前缀）。Get在name不存在时
+// 返回包装了os.ErrNotExist的错误，方便调用方统一用errors.Is判断，不必关心具体backend。
+// 所有方法都接受ctx，以便在收到中断信号时尽快放弃未完成的上传/下载，
+// 与backupCurrentWorld、archiver.Compress/Extract等其他长耗时操作保持同一套取消惯例
+type StorageBackend interface {
+	Put(ctx context.Context, name string, r io.Reader) error
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	List(ctx context.Context) ([]ObjectInfo, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// StorageConfig 配置远程备份存储后端的连接信息。Type为空或"local"时，
+// backupCurrentWorld只把备份写到本地./backup目录，不推送到远程；回档时-backup传入的
+// 远程URI按URI本身构造backend，与Type无关
+type StorageConfig struct {
+	Type   string       `json:"type"` // ""/"local" / "s3" / "webdav" / "sftp"
+	S3     S3Config     `json:"s3"`
+	WebDAV WebDAVConfig `json:"webdav"`
+	SFTP   SFTPConfig   `json:"sftp"`
+	// HTTP只在回档时从http://、https://形式的远程URI读取归档会用到，backupCurrentWorld
+	// 主动推送备份不支持该类型（回档工具没有通用的HTTP上传协议可供约定）
+	HTTP HTTPConfig `json:"http"`
+}
+
+type S3Config struct {
+	Region   string `json:"region"`
+	Endpoint string `json:"endpoint"` // 非空时指向自建/兼容S3服务（如MinIO），留空则使用AWS默认endpoint
+	// Bucket只在backupCurrentWorld主动推送备份（resolveConfiguredStorageBackend）时使用；
+	// 解析s3://bucket/key这类URI时bucket取自URI本身，这个字段不生效
+	Bucket          string `json:"bucket"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	UsePathStyle    bool   `json:"use_path_style"`
+}
+
+type WebDAVConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type SFTPConfig struct {
+	Host           string `json:"host"`
+	Port           int    `json:"port"`
+	User           string `json:"user"`
+	Password       string `json:"password"`
+	PrivateKeyPath string `json:"private_key_path"`
+}
+
+// HTTPConfig 配置从普通HTTP(S)服务器拉取归档时使用的认证方式。BearerToken非空时优先生效，
+// 以Authorization: Bearer <token>发送；否则Username非空时退回HTTP Basic认证。
+// 两者留空时均回退读取对应的环境变量，避免把凭证写进配置文件，
+// 与AWS_ACCESS_KEY_ID这类云厂商SDK通过环境变量注入凭证的惯例保持一致
+type HTTPConfig struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	BearerToken string `json:"bearer_token"`
+}
+
+const (
+	envHTTPBearerToken = "EASYBACKUPER_HTTP_BEARER_TOKEN"
+	envHTTPUsername    = "EASYBACKUPER_HTTP_USERNAME"
+	envHTTPPassword    = "EASYBACKUPER_HTTP_PASSWORD"
+)
+
+// isRemoteBackupURI 判断备份路径是否是s3://、webdav://、sftp://或http(s)://这类远程URI，
+// 而非本地文件系统路径
+func isRemoteBackupURI(p string) bool {
+	for _, scheme := range []string{"s3://", "webdav://", "sftp://", "http://", "https://"} {
+		if strings.HasPrefix(p, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveStorageBackend 解析一个远程备份URI，返回对应的StorageBackend以及
+// backend内部寻址用的相对key（例如s3://bucket/key/name.tar.gz的"key/name.tar.gz"）。
+// bucket/host等寻址信息取自URI本身，AccessKey/密码等凭证从pluginConfig.Backup.Storage读取
+func resolveStorageBackend(uri string) (StorageBackend, string, error) {
+	switch {
+	case strings.HasPrefix(uri, "s3://"):
+		return resolveS3Backend(uri)
+	case strings.HasPrefix(uri, "webdav://"):
+		return resolveWebDAVBackend(uri)
+	case strings.HasPrefix(uri, "sftp://"):
+		return resolveSFTPBackend(uri)
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
+		return resolveHTTPBackend(uri)
+	default:
+		return nil, "", fmt.Errorf("不支持的远程备份URI: %s", uri)
+	}
+}
+
+// archiveNameHint从归档路径/URI中提取一个适合喂给archiver.Identify做扩展名兜底猜测的文件名。
+// 本地路径和s3/webdav/sftp这类URI本身的尾段就是一个干净的文件名，直接取filepath.Base即可；
+// http(s)://URI的query string、签名参数等可能跟在文件名后面（例如预签名下载链接），
+// 必须先解析出URL的path部分再取Base，否则会把一长串query参数误当成"扩展名"的一部分
+func archiveNameHint(archivePath string) string {
+	if strings.HasPrefix(archivePath, "http://") || strings.HasPrefix(archivePath, "https://") {
+		if u, err := url.Parse(archivePath); err == nil {
+			return path.Base(u.Path)
+		}
+	}
+	return filepath.Base(archivePath)
+}
+
+// downloadToTempFile 把一个远程归档完整拉取到本地临时文件，供外部7z可执行文件
+// 或分块zstd的TOC偏移量seek这类需要本地可随机访问文件的路径使用。
+// 返回的cleanup负责在调用方用完后删除该临时文件。http(s)来源走
+// httpStorageBackend.downloadWithResume，支持传输中断后用Range请求续传，
+// 而不必每次重试都从零字节重新下载
+func downloadToTempFile(ctx context.Context, uri string) (path string, cleanup func(), err error) {
+	backend, key, err := resolveStorageBackend(uri)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "easybackuper-remote-*"+filepath.Ext(archiveNameHint(uri)))
+	if err != nil {
+		return "", nil, fmt.Errorf("创建临时文件失败: %v", err)
+	}
+	tmpPath := tmp.Name()
+	cleanup = func() { os.Remove(tmpPath) }
+
+	if httpBackend, ok := backend.(*httpStorageBackend); ok {
+		tmp.Close()
+		if err := httpBackend.downloadWithResume(ctx, key, tmpPath, newThrottledProgress()); err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("下载远程归档失败: %v", err)
+		}
+		return tmpPath, cleanup, nil
+	}
+
+	rc, err := backend.Get(ctx, key)
+	if err != nil {
+		tmp.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("从远程存储读取归档失败: %v", err)
+	}
+	defer rc.Close()
+
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("下载远程归档失败: %v", err)
+	}
+	tmp.Close()
+
+	return tmpPath, cleanup, nil
+}
+
+// readSidecarBytes 读取manifest/whiteouts这类归档旁边的sidecar文件，
+// backupPath可以是本地路径也可以是s3/webdav/sftp远程URI
+func readSidecarBytes(ctx context.Context, backupPath string) ([]byte, error) {
+	if !isRemoteBackupURI(backupPath) {
+		return os.ReadFile(backupPath)
+	}
+
+	backend, key, err := resolveStorageBackend(backupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+// resolveConfiguredStorageBackend 按pluginConfig.Backup.Storage.Type构造对应的远程
+// StorageBackend，Type为空或"local"时返回nil（调用方应回退到本地磁盘）。
+// 与resolveStorageBackend不同，这里不解析URI，bucket/host等寻址信息也来自配置本身，
+// 用于backupCurrentWorld主动推送新产出的备份到远程存储
+func resolveConfiguredStorageBackend() (StorageBackend, error) {
+	storage := currentConfig().Backup.Storage
+	switch storage.Type {
+	case "", "local":
+		return nil, nil
+	case "s3":
+		return newS3Backend(storage.S3.Bucket, storage.S3)
+	case "webdav":
+		if storage.WebDAV.URL == "" {
+			return nil, fmt.Errorf("未配置Backup.Storage.webdav.url")
+		}
+		return &webdavStorageBackend{client: gowebdav.NewClient(storage.WebDAV.URL, storage.WebDAV.Username, storage.WebDAV.Password)}, nil
+	case "sftp":
+		port := storage.SFTP.Port
+		if port == 0 {
+			port = 22
+		}
+		return newSFTPBackend(storage.SFTP.Host, port, storage.SFTP.User, storage.SFTP)
+	default:
+		return nil, fmt.Errorf("不支持的storage.type: %s", storage.Type)
+	}
+}
+
+// uploadBackupArtifacts 在backupCurrentWorld本地产出一份备份归档后，把它连同旁边的
+// metadata/manifest/whiteouts sidecar一并推送到已配置的远程存储；未配置远程存储
+// （Type为空或"local"）时什么也不做，继续只用本地./backup目录
+func uploadBackupArtifacts(ctx context.Context, localPaths ...string) error {
+	backend, err := resolveConfiguredStorageBackend()
+	if err != nil {
+		return fmt.Errorf("解析远程存储配置失败: %v", err)
+	}
+	if backend == nil {
+		return nil
+	}
+
+	for _, localPath := range localPaths {
+		if _, err := os.Stat(localPath); err != nil {
+			continue // sidecar文件可能不存在（例如全量备份没有.whiteouts.json），跳过即可
+		}
+		if err := func() error {
+			f, err := os.Open(localPath)
+			if err != nil {
+				return fmt.Errorf("打开 %s 失败: %v", localPath, err)
+			}
+			defer f.Close()
+			return backend.Put(ctx, filepath.Base(localPath), f)
+		}(); err != nil {
+			return fmt.Errorf("推送备份产物到远程存储失败: %v", err)
+		}
+		pluginPrint(fmt.Sprintf("已推送 %s 到远程存储", filepath.Base(localPath)), "INFO")
+	}
+	return nil
+}
+
+// ---- 本地磁盘 ----
+
+// localStorageBackend 把StorageBackend接口套在本地目录上，主要用于和远程backend
+// 共享同一套Put/Get/List/Delete调用方式；目前main()中的回档流程和backupCurrentWorld
+// 对本地路径仍走原有的直接os.Open/os.Create，这里保留实现供未来统一调用
+type localStorageBackend struct {
+	baseDir string
+}
+
+func newLocalStorageBackend(baseDir string) *localStorageBackend {
+	return &localStorageBackend{baseDir: baseDir}
+}
+
+func (b *localStorageBackend) Put(ctx context.Context, name string, r io.Reader) error {
+	fullPath := filepath.Join(b.baseDir, name)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+	out, err := os.Create(fullPath)
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %v", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	return nil
+}
+
+func (b *localStorageBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(b.baseDir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (b *localStorageBackend) List(ctx context.Context) ([]ObjectInfo, error) {
+	entries, err := os.ReadDir(b.baseDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var result []ObjectInfo
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		result = append(result, ObjectInfo{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return result, nil
+}
+
+func (b *localStorageBackend) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(b.baseDir, name))
+}
+
+// ---- S3 ----
+
+// s3StorageBackend 基于aws-sdk-go-v2的S3实现，Endpoint非空时指向自建/
+// 兼容S3服务（例如MinIO），否则使用AWS默认endpoint解析
+type s3StorageBackend struct {
+	client *s3.Client
+	bucket string
+}
+
+// resolveS3Backend 解析s3://bucket/key形式的URI，bucket取自URI本身，
+// 凭证/region/endpoint从pluginConfig.Backup.Storage.S3读取
+func resolveS3Backend(uri string) (StorageBackend, string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, "", fmt.Errorf("解析S3 URI失败: %v", err)
+	}
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, "", fmt.Errorf("无效的S3 URI（需要s3://bucket/key形式）: %s", uri)
+	}
+
+	cfg := pluginConfig.Backup.Storage.S3
+
+	backend, err := newS3Backend(bucket, cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	return backend, key, nil
+}
+
+func newS3Backend(bucket string, cfg S3Config) (*s3StorageBackend, error) {
+	ctx := context.Background()
+
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("加载S3客户端配置失败: %v", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+
+	return &s3StorageBackend{client: client, bucket: bucket}, nil
+}
+
+func (b *s3StorageBackend) Put(ctx context.Context, name string, r io.Reader) error {
+	uploader := manager.NewUploader(b.client)
+	_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(name),
+		Body:   r,
+	})
+	if err != nil {
+		return fmt.Errorf("上传S3对象失败: %v", err)
+	}
+	return nil
+}
+
+func (b *s3StorageBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("读取S3对象失败: %v", err)
+	}
+	return out.Body, nil
+}
+
+func (b *s3StorageBackend) List(ctx context.Context) ([]ObjectInfo, error) {
+	var result []ObjectInfo
+	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{Bucket: aws.String(b.bucket)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("列出S3对象失败: %v", err)
+		}
+		for _, obj := range page.Contents {
+			result = append(result, ObjectInfo{Name: aws.ToString(obj.Key), Size: aws.ToInt64(obj.Size), ModTime: aws.ToTime(obj.LastModified)})
+		}
+	}
+	return result, nil
+}
+
+func (b *s3StorageBackend) Delete(ctx context.Context, name string) error {
+	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		return fmt.Errorf("删除S3对象失败: %v", err)
+	}
+	return nil
+}
+
+// ---- WebDAV ----
+
+// webdavStorageBackend 基于github.com/studio-b12/gowebdav的WebDAV实现，
+// URL/用户名/密码都来自pluginConfig.Backup.Storage.WebDAV，
+// webdav://开头的URI剩余部分就是该WebDAV根下的相对路径
+type webdavStorageBackend struct {
+	client *gowebdav.Client
+}
+
+func resolveWebDAVBackend(uri string) (StorageBackend, string, error) {
+	relPath := strings.TrimPrefix(uri, "webdav://")
+	if relPath == "" {
+		return nil, "", fmt.Errorf("无效的WebDAV URI: %s", uri)
+	}
+
+	cfg := pluginConfig.Backup.Storage.WebDAV
+	if cfg.URL == "" {
+		return nil, "", fmt.Errorf("未配置Backup.Storage.webdav.url，无法解析远程URI: %s", uri)
+	}
+
+	client := gowebdav.NewClient(cfg.URL, cfg.Username, cfg.Password)
+	return &webdavStorageBackend{client: client}, relPath, nil
+}
+
+func (b *webdavStorageBackend) Put(ctx context.Context, name string, r io.Reader) error {
+	if err := b.client.MkdirAll(path.Dir(name), 0755); err != nil {
+		return fmt.Errorf("创建WebDAV目录失败: %v", err)
+	}
+	if err := b.client.WriteStream(name, r, 0644); err != nil {
+		return fmt.Errorf("写入WebDAV文件失败: %v", err)
+	}
+	return nil
+}
+
+func (b *webdavStorageBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	rc, err := b.client.ReadStream(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("读取WebDAV文件失败: %v", err)
+	}
+	return rc, nil
+}
+
+func (b *webdavStorageBackend) List(ctx context.Context) ([]ObjectInfo, error) {
+	infos, err := b.client.ReadDir("/")
+	if err != nil {
+		return nil, fmt.Errorf("列出WebDAV目录失败: %v", err)
+	}
+
+	var result []ObjectInfo
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		result = append(result, ObjectInfo{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return result, nil
+}
+
+func (b *webdavStorageBackend) Delete(ctx context.Context, name string) error {
+	if err := b.client.Remove(name); err != nil {
+		return fmt.Errorf("删除WebDAV文件失败: %v", err)
+	}
+	return nil
+}
+
+// ---- SFTP ----
+
+// sftpStorageBackend 基于golang.org/x/crypto/ssh + github.com/pkg/sftp的实现，
+// 认证信息优先取sftp://URI中携带的user@host，密码/私钥仍从
+// pluginConfig.Backup.Storage.SFTP读取
+type sftpStorageBackend struct {
+	sshClient  *ssh.Client
+	sftpClient *sftp.Client
+}
+
+func resolveSFTPBackend(uri string) (StorageBackend, string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, "", fmt.Errorf("解析SFTP URI失败: %v", err)
+	}
+
+	cfg := pluginConfig.Backup.Storage.SFTP
+
+	host := u.Hostname()
+	if host == "" {
+		host = cfg.Host
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+	if u.Port() != "" {
+		if p, err := strconv.Atoi(u.Port()); err == nil {
+			port = p
+		}
+	}
+
+	user := cfg.User
+	if u.User != nil && u.User.Username() != "" {
+		user = u.User.Username()
+	}
+
+	remotePath := strings.TrimPrefix(u.Path, "/")
+	if host == "" || remotePath == "" {
+		return nil, "", fmt.Errorf("无效的SFTP URI（需要sftp://host/path或在Backup.Storage.sftp中配置host）: %s", uri)
+	}
+
+	backend, err := newSFTPBackend(host, port, user, cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	return backend, remotePath, nil
+}
+
+func newSFTPBackend(host string, port int, user string, cfg SFTPConfig) (*sftpStorageBackend, error) {
+	authMethods, err := sftpAuthMethods(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		User:            user,
+		Auth:            authMethods,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 回档工具面向的是运维方自己管理的远端存储，暂不做known_hosts校验
+	}
+
+	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host, port), sshConfig)
+	if err != nil {
+		return nil, fmt.Errorf("连接SFTP服务器失败: %v", err)
+	}
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("创建SFTP客户端失败: %v", err)
+	}
+
+	return &sftpStorageBackend{sshClient: sshClient, sftpClient: sftpClient}, nil
+}
+
+func sftpAuthMethods(cfg SFTPConfig) ([]ssh.AuthMethod, error) {
+	if cfg.PrivateKeyPath != "" {
+		keyData, err := os.ReadFile(cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取SFTP私钥失败: %v", err)
+		}
+		signer, err := ssh.ParsePrivateKey(keyData)
+		if err != nil {
+			return nil, fmt.Errorf("解析SFTP私钥失败: %v", err)
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+	return []ssh.AuthMethod{ssh.Password(cfg.Password)}, nil
+}
+
+func (b *sftpStorageBackend) Put(ctx context.Context, name string, r io.Reader) error {
+	if err := b.sftpClient.MkdirAll(path.Dir(name)); err != nil {
+		return fmt.Errorf("创建SFTP目录失败: %v", err)
+	}
+	out, err := b.sftpClient.Create(name)
+	if err != nil {
+		return fmt.Errorf("创建SFTP文件失败: %v", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("写入SFTP文件失败: %v", err)
+	}
+	return nil
+}
+
+func (b *sftpStorageBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	f, err := b.sftpClient.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("读取SFTP文件失败: %v", err)
+	}
+	return f, nil
+}
+
+func (b *sftpStorageBackend) List(ctx context.Context) ([]ObjectInfo, error) {
+	infos, err := b.sftpClient.ReadDir("/")
+	if err != nil {
+		return nil, fmt.Errorf("列出SFTP目录失败: %v", err)
+	}
+
+	var result []ObjectInfo
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		result = append(result, ObjectInfo{Name: info.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return result, nil
+}
+
+func (b *sftpStorageBackend) Delete(ctx context.Context, name string) error {
+	if err := b.sftpClient.Remove(name); err != nil {
+		return fmt.Errorf("删除SFTP文件失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// nbtTagCompound是NBT格式里compound tag的类型ID，Bedrock的level.dat根标签固定是一个compound，
+// 用来做廉价的"这像不像一个NBT文件"检查，而不是完整解析整棵NBT树
+const nbtTagCompound = 0x0a
+
+// verifyWorldDirStructure在把解压出的世界目录换入worlds/之前，核实它至少具备一个
+// Bedrock世界应有的基本文件结构（level.dat、levelname.txt、db/下至少一个.ldb或CURRENT），
+// 避免一份被截断/损坏的归档"解压成功"却只落了半棵文件树，最终在复制阶段把现有世界目录冲掉
+func verifyWorldDirStructure(worldDir string) error {
+	for _, name := range []string{"level.dat", "levelname.txt"} {
+		path := filepath.Join(worldDir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("解压出的世界目录缺少必需文件 %s: %v", name, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("解压出的世界目录里 %s 应该是文件，实际是目录", name)
+		}
+	}
+
+	dbDir := filepath.Join(worldDir, "db")
+	entries, err := os.ReadDir(dbDir)
+	if err != nil {
+		return fmt.Errorf("解压出的世界目录缺少db子目录: %v", err)
+	}
+
+	hasLDBOrCurrent := false
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if entry.Name() == "CURRENT" || filepath.Ext(entry.Name()) == ".ldb" {
+			hasLDBOrCurrent = true
+			break
+		}
+	}
+	if !hasLDBOrCurrent {
+		return fmt.Errorf("解压出的世界目录的db子目录下没有找到CURRENT或任何.ldb文件，世界数据可能不完整")
+	}
+
+	return nil
+}
+
+// verifyLevelDatHeader对level.dat做一次轻量级的sanity check而非完整NBT解析：
+// Bedrock的level.dat格式为4字节小端version + 4字节小端payload长度，后面紧跟NBT数据，
+// 其根标签固定是compound（tag类型0x0a）。只要这几个字段读不出来或对不上，
+// 就说明这份level.dat大概率被截断或损坏了
+func verifyLevelDatHeader(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取level.dat失败: %v", err)
+	}
+	if len(data) < 9 {
+		return fmt.Errorf("level.dat文件过小（%d字节），不像是一份完整的世界存档", len(data))
+	}
+
+	payloadLen := int(binary.LittleEndian.Uint32(data[4:8]))
+	if payloadLen <= 0 {
+		return fmt.Errorf("level.dat头部记录的payload长度非法: %d", payloadLen)
+	}
+	if 8+payloadLen > len(data) {
+		return fmt.Errorf("level.dat头部记录的payload长度（%d字节）超出文件头之后的实际数据大小（%d字节），文件可能被截断", payloadLen, len(data)-8)
+	}
+
+	if data[8] != nbtTagCompound {
+		return fmt.Errorf("level.dat的NBT根标签类型不是compound（0x0a），实际为0x%02x", data[8])
+	}
+
+	return nil
+}
+
+// verifyRestoredWorld是verifyWorldDirStructure和verifyLevelDatHeader的统一入口，
+// 在解压完成、换入现有世界目录之前调用，校验失败则中止回档且不触碰当前世界目录
+func verifyRestoredWorld(worldDir string) error {
+	if err := verifyWorldDirStructure(worldDir); err != nil {
+		return err
+	}
+	return verifyLevelDatHeader(filepath.Join(worldDir, "level.dat"))
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdTOCSuffix 是分块zstd归档配套索引文件的后缀，约定放在归档文件旁边，
+// 和manifest/whiteouts sidecar是同一套命名习惯
+const zstdTOCSuffix = ".toc.json"
+
+// ZstdChunkEntry 记录分块zstd归档中单个文件对应的独立zstd帧在文件中的位置，
+// 使得解压时可以跳过不需要的文件，不必从头流式解码整个归档
+type ZstdChunkEntry struct {
+	Path             string `json:"path"`
+	Offset           int64  `json:"offset"`
+	CompressedSize   int64  `json:"compressed_size"`
+	UncompressedSize int64  `json:"uncompressed_size"`
+}
+
+// ZstdTOC 是一次tar.zst分块压缩产出的完整帧索引
+type ZstdTOC struct {
+	Entries []ZstdChunkEntry `json:"entries"`
+}
+
+// compressWithTarZstd 把srcDir下的每个文件各自打包成一个独立的单文件tar再压缩成
+// 一个独立的zstd帧，依次拼接写入dst，并在旁边写出记录每帧偏移量的TOC。
+// 相比把整个目录打成一个tar再整体过一遍zstd，这样做能让后续解压按需seek到单个
+// 文件的帧，不必解码它之前的内容——这正是zstd-chunked用在容器镜像层上的思路
+func compressWithTarZstd(ctx context.Context, srcDir, dst string, progress Progress) error {
+	var files []string
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("遍历待压缩目录失败: %v", err)
+	}
+	sort.Strings(files)
+
+	var totalBytes int64
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("读取文件信息失败 %s: %v", path, err)
+		}
+		totalBytes += info.Size()
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("创建压缩文件失败: %v", err)
+	}
+	defer out.Close()
+
+	compression := currentConfig().Compression
+	concurrency := compression.Threads
+	if concurrency <= 0 {
+		concurrency = globalConfig.MaxWorkers
+	}
+	if concurrency <= 0 {
+		concurrency = defaultMaxWorkers
+	}
+
+	level := compression.Level
+
+	pluginPrint(fmt.Sprintf("使用分块zstd压缩（并发度%d，压缩级别%d）: %s --> %s", concurrency, level, srcDir, dst), "INFO")
+
+	var toc ZstdTOC
+	var offset, doneBytes int64
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return fmt.Errorf("计算相对路径失败: %v", err)
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		frame, uncompressedSize, err := encodeZstdFrame(path, relPath, concurrency, level)
+		if err != nil {
+			return fmt.Errorf("编码zstd帧失败 %s: %v", relPath, err)
+		}
+
+		if _, err := out.Write(frame); err != nil {
+			return fmt.Errorf("写入压缩帧失败 %s: %v", relPath, err)
+		}
+
+		toc.Entries = append(toc.Entries, ZstdChunkEntry{
+			Path:             relPath,
+			Offset:           offset,
+			CompressedSize:   int64(len(frame)),
+			UncompressedSize: uncompressedSize,
+		})
+		offset += int64(len(frame))
+
+		doneBytes += uncompressedSize
+		if progress != nil {
+			progress.Update(doneBytes, totalBytes, relPath)
+		}
+	}
+
+	if err := writeZstdTOC(dst, &toc); err != nil {
+		return err
+	}
+
+	pluginPrint("分块zstd压缩完成", "SUCCESS")
+	pluginPrint(fmt.Sprintf("备份文件已保存: %s", dst), "SUCCESS")
+	return nil
+}
+
+// encodeZstdFrame 为单个文件构造一个自包含的zstd帧（内部是只含这一个文件的tar流），
+// 使其今后可以脱离归档中的其它帧被独立定位和解码
+func encodeZstdFrame(path, relPath string, concurrency, level int) ([]byte, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	header.Name = relPath
+
+	if err := tw.WriteHeader(header); err != nil {
+		return nil, 0, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, copyErr := io.Copy(tw, file)
+	file.Close()
+	if copyErr != nil {
+		return nil, 0, copyErr
+	}
+	if err := tw.Close(); err != nil {
+		return nil, 0, err
+	}
+
+	opts := []zstd.EOption{zstd.WithEncoderConcurrency(concurrency)}
+	if level > 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevel(level)))
+	}
+
+	var zstdBuf bytes.Buffer
+	enc, err := zstd.NewWriter(&zstdBuf, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := enc.Write(tarBuf.Bytes()); err != nil {
+		enc.Close()
+		return nil, 0, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, 0, err
+	}
+
+	return zstdBuf.Bytes(), info.Size(), nil
+}
+
+// writeZstdTOC 把帧索引写到归档文件旁边的sidecar
+func writeZstdTOC(archivePath string, toc *ZstdTOC) error {
+	data, err := json.MarshalIndent(toc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化zstd TOC失败: %v", err)
+	}
+	if err := os.WriteFile(archivePath+zstdTOCSuffix, data, 0644); err != nil {
+		return fmt.Errorf("写入zstd TOC失败: %v", err)
+	}
+	return nil
+}
+
+// readZstdTOC 读取归档文件旁的帧索引；不存在时返回nil而非错误，
+// 因为不是所有tar.zst归档都是以分块方式产出的（例如旧版本archiver生成的单帧zstd流）
+func readZstdTOC(archivePath string) (*ZstdTOC, error) {
+	data, err := os.ReadFile(archivePath + zstdTOCSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取zstd TOC失败: %v", err)
+	}
+
+	var toc ZstdTOC
+	if err := json.Unmarshal(data, &toc); err != nil {
+		return nil, fmt.Errorf("解析zstd TOC失败: %v", err)
+	}
+	return &toc, nil
+}
+
+// extractTarZstdChunked 依据TOC按需解压分块zstd归档。onlyGlob非空时，
+// 只有路径匹配的帧会被seek并解码，其余帧整体跳过，不产生解压开销
+func extractTarZstdChunked(ctx context.Context, archivePath, destDir, onlyGlob string, progress Progress) error {
+	toc, err := readZstdTOC(archivePath)
+	if err != nil {
+		return err
+	}
+	if toc == nil {
+		return fmt.Errorf("分块zstd归档缺少TOC sidecar: %s", archivePath)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("打开压缩文件失败: %v", err)
+	}
+	defer file.Close()
+
+	var entries []ZstdChunkEntry
+	var totalBytes int64
+	for _, entry := range toc.Entries {
+		if onlyGlob != "" {
+			matched, err := filepath.Match(onlyGlob, entry.Path)
+			if err != nil {
+				return fmt.Errorf("无效的-only匹配模式: %v", err)
+			}
+			if !matched {
+				continue
+			}
+		}
+		entries = append(entries, entry)
+		totalBytes += entry.UncompressedSize
+	}
+
+	if onlyGlob != "" {
+		pluginPrint(fmt.Sprintf("按-only过滤条件 %s 只解压 %d/%d 个文件，其余分帧整体跳过", onlyGlob, len(entries), len(toc.Entries)), "INFO")
+	}
+
+	var doneBytes int64
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := file.Seek(entry.Offset, io.SeekStart); err != nil {
+			return fmt.Errorf("定位到分帧偏移失败 %s: %v", entry.Path, err)
+		}
+
+		frameReader := io.LimitReader(file, entry.CompressedSize)
+		if err := extractZstdFrame(frameReader, destDir, &doneBytes, totalBytes, progress); err != nil {
+			return fmt.Errorf("解压分帧 %s 失败: %v", entry.Path, err)
+		}
+	}
+
+	pluginPrint("分块zstd解压完成", "SUCCESS")
+	return nil
+}
+
+// extractZstdFrame 解码一个独立zstd帧（内部只含单个文件的tar流）并落盘
+func extractZstdFrame(r io.Reader, destDir string, doneBytes *int64, totalBytes int64, progress Progress) error {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	tr := tar.NewReader(dec)
+	header, err := tr.Next()
+	if err != nil {
+		return err
+	}
+
+	targetPath, err := safeExtractEntryPath(destDir, filepath.FromSlash(header.Name))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+
+	outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	reader := &countingReader{r: tr, done: doneBytes, total: totalBytes, path: header.Name, progress: progress}
+	if _, err := io.Copy(outFile, reader); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/mholt/archiver/v4"
+)
+
+// Archiver is a unified compress/extract interface hiding the differences
+// between zip/7z/tar.gz/tar.xz/tar.zst and other formats.
+// ExternalExe7zPath only kicks in when it is non-empty; by default everything
+// goes through the pure-Go implementation.
+// progress may be nil when the caller does not care about progress reporting.
+// onlyGlob, when non-empty, restricts extraction to entries whose relative
+// path matches the glob (e.g. "db/*" or a specific dimension directory)
+type Archiver interface {
+	Compress(ctx context.Context, srcDir, dst string, progress Progress) error
+	Extract(ctx context.Context, archivePath, destDir, onlyGlob string, progress Progress) error
+}
+
+// nativeArchiver is a pure-Go implementation built on
+// github.com/mholt/archiver/v4. Extraction sniffs the format from the file
+// header; compression picks a writer based on the destination file name.
+type nativeArchiver struct {
+	// externalExe7zPath is non-empty only when the user explicitly opted
+	// into using an external 7z executable
+	externalExe7zPath string
+}
+
+// newArchiver builds the default archiver. externalExe7zPath should only be
+// non-empty when the user filled in exe_7z_path in config and chose to keep
+// using the external tool
+func newArchiver(externalExe7zPath string) Archiver {
+	return &nativeArchiver{externalExe7zPath: externalExe7zPath}
+}
+
+func (a *nativeArchiver) Extract(ctx context.Context, archivePath, destDir, onlyGlob string, progress Progress) error {
+	isRemote := isRemoteBackupURI(archivePath)
+
+	// External 7z needs a real file path to hand to exec, and chunked zstd seeks using
+	// byte offsets recorded in its TOC; both paths need a local, randomly-accessible
+	// file, so a remote archive is fully downloaded to a temp file before reaching them
+	if isRemote && (a.externalExe7zPath != "" || hasAnySuffix(archivePath, ".tar.zst", ".tzst")) {
+		localPath, cleanup, err := downloadToTempFile(ctx, archivePath)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		archivePath = localPath
+		isRemote = false
+	}
+
+	if a.externalExe7zPath != "" {
+		if onlyGlob != "" {
+			pluginPrint("External 7z extraction does not support -only subset filtering, extracting the full archive", "WARNING")
+		}
+		return extractWithExternal7z(a.externalExe7zPath, archivePath, destDir)
+	}
+
+	// A chunked zstd archive has its own TOC sidecar that supports seeking straight to
+	// matching frames instead of streaming the whole archive; prefer this path whenever
+	// the TOC exists, even with an empty onlyGlob (just without the time savings)
+	if hasAnySuffix(archivePath, ".tar.zst", ".tzst") {
+		if _, err := os.Stat(archivePath + zstdTOCSuffix); err == nil {
+			pluginPrint(fmt.Sprintf("Detected chunked zstd archive TOC, extracting on demand: %s --> %s", archivePath, destDir), "INFO")
+			return extractTarZstdChunked(ctx, archivePath, destDir, onlyGlob, progress)
+		}
+	}
+
+	var file io.ReadCloser
+	var err error
+	if isRemote {
+		pluginPrint(fmt.Sprintf("Streaming extraction from remote storage, no need to download the full archive first: %s --> %s", archivePath, destDir), "INFO")
+		backend, key, resolveErr := resolveStorageBackend(archivePath)
+		if resolveErr != nil {
+			return resolveErr
+		}
+		file, err = backend.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to read archive from remote storage: %v", err)
+		}
+	} else {
+		file, err = os.Open(archivePath)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %v", err)
+		}
+	}
+	defer file.Close()
+
+	format, reader, err := archiver.Identify(archiveNameHint(archivePath), file)
+	if err != nil {
+		return fmt.Errorf("failed to identify archive format (sniffed from file header, not just the extension): %v", err)
+	}
+
+	extractor, ok := format.(archiver.Extractor)
+	if !ok {
+		return fmt.Errorf("format %s does not support extraction", format.Name())
+	}
+
+	pluginPrint(fmt.Sprintf("Detected archive format: %s, extracting: %s --> %s", format.Name(), archivePath, destDir), "INFO")
+
+	// Header parsing (driven single-threaded by the archive library, in tar/zip entry
+	// order) is decoupled from disk writes: the parser goroutine just reads each entry's
+	// content into memory in order and immediately drops a write job onto a bounded jobs
+	// channel; the worker pool below does the actual disk writes concurrently, so write
+	// order is no longer constrained by tar entry order
+	maxWorkers := globalConfig.MaxWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = defaultMaxWorkers
+	}
+
+	jobs := make(chan extractJob, maxWorkers*2)
+	jobErrors := make(chan error, maxWorkers)
+	var wg sync.WaitGroup
+	var doneBytes int64
+
+	for i := 0; i < maxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := writeExtractJob(job, &doneBytes, progress); err != nil {
+					select {
+					case jobErrors <- err:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	// The total extracted size can't be known ahead of time (the archive can only be sniffed
+	// while reading), so total is passed as -1 and progress only shows bytes done so far
+	extractErr := extractor.Extract(ctx, reader, func(entryCtx context.Context, f archiver.File) error {
+		if err := entryCtx.Err(); err != nil {
+			return err
+		}
+		if onlyGlob != "" {
+			matched, err := filepath.Match(onlyGlob, f.NameInArchive)
+			if err != nil {
+				return fmt.Errorf("invalid -only match pattern: %v", err)
+			}
+			if !matched {
+				return nil
+			}
+		}
+
+		targetPath, err := safeExtractEntryPath(destDir, f.NameInArchive)
+		if err != nil {
+			return err
+		}
+
+		if f.IsDir() {
+			return os.MkdirAll(targetPath, 0755)
+		}
+
+		src, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open archive entry: %v", err)
+		}
+		data, err := io.ReadAll(src)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read archive entry: %v", err)
+		}
+
+		job := extractJob{relPath: f.NameInArchive, targetPath: targetPath, mode: f.Mode(), data: data}
+		select {
+		case jobs <- job:
+		case <-entryCtx.Done():
+			return entryCtx.Err()
+		}
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+
+	if extractErr != nil {
+		return fmt.Errorf("extraction failed: %v", extractErr)
+	}
+	select {
+	case err := <-jobErrors:
+		return fmt.Errorf("failed to write extracted file: %v", err)
+	default:
+	}
+
+	pluginPrint("Extraction completed", "SUCCESS")
+	return nil
+}
+
+// extractJob is a disk-write task handed from the parser goroutine to the worker
+// pool: the entry's content is already read into memory, and targetPath has
+// already been validated by safeExtractEntryPath to fall inside destDir, so a
+// worker only needs to write it out and never touches the archive's read state
+type extractJob struct {
+	relPath    string // used for progress reporting only
+	targetPath string
+	mode       os.FileMode
+	data       []byte
+}
+
+// safeExtractEntryPath rejects an archive entry that is an absolute path or
+// that would escape destDir via a relative path like "../" (a zip-slip), and
+// returns the validated destination path under destDir
+func safeExtractEntryPath(destDir, nameInArchive string) (string, error) {
+	if filepath.IsAbs(nameInArchive) {
+		return "", fmt.Errorf("archive entry uses an absolute path, refusing to extract: %s", nameInArchive)
+	}
+
+	destAbs, err := filepath.Abs(destDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve destination directory absolute path: %v", err)
+	}
+	targetAbs, err := filepath.Abs(filepath.Join(destDir, nameInArchive))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve archive entry destination path: %v", err)
+	}
+	if targetAbs != destAbs && !strings.HasPrefix(targetAbs, destAbs+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry path escapes the destination directory (zip-slip), refusing to extract: %s", nameInArchive)
+	}
+
+	return targetAbs, nil
+}
+
+// writeExtractJob writes an archive entry that has already been read into
+// memory to job.targetPath
+func writeExtractJob(job extractJob, doneBytes *int64, progress Progress) error {
+	if err := os.MkdirAll(filepath.Dir(job.targetPath), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %v", err)
+	}
+
+	out, err := os.OpenFile(job.targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, job.mode)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer out.Close()
+
+	reader := &countingReader{r: bytes.NewReader(job.data), done: doneBytes, total: -1, path: job.relPath, progress: progress}
+	if _, err := io.Copy(out, reader); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+
+	return nil
+}
+
+func (a *nativeArchiver) Compress(ctx context.Context, srcDir, dst string, progress Progress) error {
+	if a.externalExe7zPath != "" {
+		return compressWithExternal7z(a.externalExe7zPath, srcDir, dst)
+	}
+
+	// tar.zst goes through its own chunked implementation (one self-contained zstd frame
+	// per file, plus a TOC sidecar) rather than the generic archiver.Archiver writer, so the
+	// resulting archive can later be extracted on demand by extractTarZstdChunked
+	if hasAnySuffix(dst, ".tar.zst", ".tzst") {
+		return compressWithTarZstd(ctx, srcDir, dst, progress)
+	}
+
+	// When Formats configures the current format's backend as "pgzip", use klauspost/pgzip's
+	// parallel encoder instead of archiver/v4's default single-threaded gzip; the output is
+	// still a standard gzip multistream, so extraction needs no separate code path
+	compression := currentConfig().Compression
+	if format, ok := compression.Formats[compression.Method]; ok && format.Backend == "pgzip" {
+		return compressWithTarPgzip(ctx, srcDir, dst, format.Level, format.NumCPU, progress)
+	}
+
+	format, err := archiveFormatForDest(dst)
+	if err != nil {
+		return err
+	}
+
+	writer, ok := format.(archiver.Archiver)
+	if !ok {
+		return fmt.Errorf("format %s does not support compression, use zip/tar.gz/tar.xz/tar.zst instead", format.Name())
+	}
+
+	files, err := archiver.FilesFromDisk(nil, map[string]string{srcDir: ""})
+	if err != nil {
+		return fmt.Errorf("failed to collect files to compress: %v", err)
+	}
+
+	var srcBytes int64
+	for _, f := range files {
+		if !f.IsDir() {
+			srcBytes += f.Size()
+		}
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %v", err)
+	}
+	defer out.Close()
+
+	pluginPrint(fmt.Sprintf("Compressing with %s: %s --> %s", format.Name(), srcDir, dst), "INFO")
+
+	// writer deals in output (compressed) bytes, while srcBytes is the original size -
+	// this is only a rough progress baseline, not an exact percentage
+	var doneBytes int64
+	dest := io.Writer(out)
+	if progress != nil {
+		dest = &countingWriter{w: out, done: &doneBytes, total: srcBytes, path: dst, progress: progress}
+	}
+
+	if err := writer.Archive(ctx, dest, files); err != nil {
+		return fmt.Errorf("compression failed: %v", err)
+	}
+
+	pluginPrint("Compression completed", "SUCCESS")
+	pluginPrint(fmt.Sprintf("Backup file saved: %s", dst), "SUCCESS")
+	return nil
+}
+
+// archiveFormatForDest picks the write format from the destination file's
+// suffix. tar.zst is intercepted earlier in Compress and routed to
+// compressWithTarZstd, so it never reaches here; 7z is currently only
+// supported for reading by the native implementation, so compressing to .7z
+// still requires the external tool and is not registered here
+func archiveFormatForDest(dst string) (archiver.Format, error) {
+	switch {
+	case hasAnySuffix(dst, ".tar.xz", ".txz"):
+		return archiver.CompressedArchive{Compression: archiver.Xz{}, Archival: archiver.Tar{}}, nil
+	case hasAnySuffix(dst, ".tar.gz", ".tgz", ".tar"):
+		return archiver.CompressedArchive{Compression: archiver.Gz{}, Archival: archiver.Tar{}}, nil
+	case hasAnySuffix(dst, ".zip"):
+		return archiver.Zip{}, nil
+	default:
+		return nil, fmt.Errorf("cannot infer archive format from file name %s", dst)
+	}
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if len(s) >= len(suffix) && equalFold(s[len(s)-len(suffix):], suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalFold is a lightweight case-insensitive comparison so we don't need to
+// pull in strings.EqualFold just for suffix matching
+func equalFold(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		ca, cb := a[i], b[i]
+		if ca >= 'A' && ca <= 'Z' {
+			ca += 'a' - 'A'
+		}
+		if cb >= 'A' && cb <= 'Z' {
+			cb += 'a' - 'A'
+		}
+		if ca != cb {
+			return false
+		}
+	}
+	return true
+}
+
+// extractWithExternal7z is only reached when the user explicitly configured
+// exe_7z_path; kept as a fallback alongside the native implementation
+func extractWithExternal7z(exe7zPath, archivePath, destDir string) error {
+	pluginPrint(fmt.Sprintf("External 7z tool enabled in config, extracting with external 7z: %s", archivePath), "INFO")
+
+	exe := exe7zPath
+	if runtime.GOOS != "windows" && exe == "" {
+		exe = "7z"
+	}
+
+	cmd := exec.Command(exe, "x", archivePath, "-o"+destDir, "-y")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("external 7z extraction failed: %v\noutput: %s", err, string(output))
+	}
+
+	pluginPrint("External 7z extraction completed", "SUCCESS")
+	return nil
+}
+
+func compressWithExternal7z(exe7zPath, srcDir, destFile string) error {
+	pluginPrint(fmt.Sprintf("External 7z tool enabled in config, compressing with external 7z: %s", srcDir), "INFO")
+
+	exe := exe7zPath
+	if runtime.GOOS != "windows" && exe == "" {
+		exe = "7z"
+	}
+
+	cmd := exec.Command(exe, "a", destFile, srcDir+string(filepath.Separator)+"*", "-y")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("external 7z compression failed: %v\noutput: %s", err, string(output))
+	}
+
+	pluginPrint("External 7z compression completed", "SUCCESS")
+	pluginPrint(fmt.Sprintf("Backup file saved: %s", destFile), "SUCCESS")
+	return nil
+}
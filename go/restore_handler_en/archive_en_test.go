@@ -0,0 +1,107 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildOrderingFixture builds a tar.gz whose entry order is deliberately scrambled: a directory
+// entry appears after its own file, a path longer than 100 bytes (the ustar limit) forces a GNU
+// long-name extension record, and a hardlink plus a symlink entry come last.
+// Extract's single-threaded parse + worker-pool write path (via mholt/archiver/v4) is exactly
+// what needs checking here: concurrent writes must not drop a directory or misplace content just
+// because the original tar order got shuffled or interrupted by a long-name/link record
+func buildOrderingFixture(t *testing.T, longPath string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	writeFile := func(name, content string) {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Format: tar.FormatGNU}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("failed to write tar header %s: %v", name, err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write tar content %s: %v", name, err)
+		}
+	}
+
+	// the long-name entry goes first, forcing the GNU long-name extension record ahead of the directory entry
+	writeFile(longPath, "long-path-content")
+
+	writeFile("base.txt", "base-content")
+
+	// the directory entry is deliberately placed after its child file — the worker pool must not depend on tar entry order
+	writeFile("nested/child.txt", "nested-content")
+	if err := tw.WriteHeader(&tar.Header{Name: "nested/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatalf("failed to write directory header: %v", err)
+	}
+
+	if err := tw.WriteHeader(&tar.Header{Name: "link.txt", Typeflag: tar.TypeLink, Linkname: "base.txt"}); err != nil {
+		t.Fatalf("failed to write hardlink header: %v", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "symlink.txt", Typeflag: tar.TypeSymlink, Linkname: "base.txt"}); err != nil {
+		t.Fatalf("failed to write symlink header: %v", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar writer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("failed to close gzip writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestExtractWorkerPoolPreservesOrderingInvariants verifies that extractJob/writeExtractJob's
+// concurrent writes do not violate tar ordering invariants: even when directory entries, a
+// long-name GNU record, and hardlink/symlink entries arrive out of the order a naive reader
+// would expect, the extracted directory structure and regular file contents still end up
+// correct and don't clobber each other
+func TestExtractWorkerPoolPreservesOrderingInvariants(t *testing.T) {
+	longPath := "nested/" + strings.Repeat("a", 150) + "/long.txt"
+	data := buildOrderingFixture(t, longPath)
+
+	srcDir := t.TempDir()
+	archivePath := filepath.Join(srcDir, "fixture.tar.gz")
+	if err := os.WriteFile(archivePath, data, 0644); err != nil {
+		t.Fatalf("failed to write fixture archive: %v", err)
+	}
+
+	destDir := t.TempDir()
+
+	prevMaxWorkers := globalConfig.MaxWorkers
+	globalConfig.MaxWorkers = 8
+	defer func() { globalConfig.MaxWorkers = prevMaxWorkers }()
+
+	if err := newArchiver("").Extract(context.Background(), archivePath, destDir, "", nil); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	assertFileContent := func(relPath, want string) {
+		t.Helper()
+		got, err := os.ReadFile(filepath.Join(destDir, relPath))
+		if err != nil {
+			t.Fatalf("failed to read extracted output %s: %v", relPath, err)
+		}
+		if string(got) != want {
+			t.Fatalf("extracted output %s content mismatch: want %q, got %q", relPath, want, string(got))
+		}
+	}
+
+	assertFileContent("base.txt", "base-content")
+	assertFileContent("nested/child.txt", "nested-content")
+	assertFileContent(longPath, "long-path-content")
+
+	if info, err := os.Stat(filepath.Join(destDir, "nested")); err != nil || !info.IsDir() {
+		t.Fatalf("nested directory was not created correctly: %v", err)
+	}
+}
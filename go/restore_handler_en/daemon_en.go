@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// command holds the long-lived state for daemon mode: the cron scheduler itself, and a
+// channel for reload notifications. It no longer holds its own config snapshot — pluginConfig
+// is already made concurrency-safe by pluginConfigMu/currentConfig(), which both the cron
+// scheduling goroutine and the SIGHUP/file-watch reload goroutine read and write through, so
+// command doesn't need to maintain a second, redundant atomic snapshot
+type command struct {
+	serverDir string
+	scheduler *cron.Cron
+	reloadCh  chan struct{}
+}
+
+// newCommand loads the initial configuration once and returns a command ready for long-lived use
+func newCommand(serverDir string) (*command, error) {
+	if err := loadConfig(serverDir); err != nil {
+		return nil, err
+	}
+	return &command{
+		serverDir: serverDir,
+		reloadCh:  make(chan struct{}, 1),
+	}, nil
+}
+
+// reload re-runs loadConfig, which atomically publishes the new config to pluginConfig for all readers
+func (c *command) reload() error {
+	if err := loadConfig(c.serverDir); err != nil {
+		return err
+	}
+	pluginPrint("Configuration reloaded", "SUCCESS")
+	select {
+	case c.reloadCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// watchConfigFile polls the config file's mtime and triggers a reload on change. This is a
+// second reload path alongside SIGHUP, useful when the config is updated by an external tool
+// rather than a kill -HUP
+func (c *command) watchConfigFile(ctx context.Context, configPath string, interval time.Duration) {
+	var lastMod time.Time
+	if info, err := os.Stat(configPath); err == nil {
+		lastMod = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(configPath)
+			if err != nil {
+				continue
+			}
+			if info.ModTime().After(lastMod) {
+				lastMod = info.ModTime()
+				pluginPrint(fmt.Sprintf("Detected configuration file change: %s", configPath), "INFO")
+				if err := c.reload(); err != nil {
+					pluginPrint(fmt.Sprintf("Failed to reload configuration: %v", err), "ERROR")
+				}
+			}
+		}
+	}
+}
+
+// runDaemon starts long-lived daemon mode: it watches SIGHUP and config file mtime changes to
+// hot-reload configuration, and when schedule is non-empty it uses robfig/cron/v3 to run world
+// backups periodically on that cron expression, until ctx is canceled (SIGINT/SIGTERM received)
+func runDaemon(ctx context.Context, serverDir, schedule string) error {
+	cmd, err := newCommand(serverDir)
+	if err != nil {
+		return err
+	}
+
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+
+	if configPath := resolveConfigPath(serverDir); configPath != "" {
+		go cmd.watchConfigFile(ctx, configPath, 5*time.Second)
+	}
+
+	if schedule != "" {
+		cmd.scheduler = cron.New()
+		if _, err := cmd.scheduler.AddFunc(schedule, func() {
+			pluginPrint(fmt.Sprintf("Running scheduled world backup (%s)", schedule), "INFO")
+			if err := backupCurrentWorld(ctx); err != nil {
+				pluginPrint(fmt.Sprintf("Scheduled backup failed: %v", err), "ERROR")
+			}
+		}); err != nil {
+			return fmt.Errorf("failed to parse -schedule expression: %v", err)
+		}
+		cmd.scheduler.Start()
+		defer cmd.scheduler.Stop()
+		pluginPrint(fmt.Sprintf("Scheduled backups enabled, cron expression: %s", schedule), "SUCCESS")
+	}
+
+	pluginPrint("Daemon mode started, waiting for SIGHUP to reload configuration or SIGINT/SIGTERM to exit", "INFO")
+
+	for {
+		select {
+		case <-ctx.Done():
+			pluginPrint("Daemon received exit signal, shutting down", "INFO")
+			return nil
+		case <-hupCh:
+			pluginPrint("Received SIGHUP, reloading configuration", "INFO")
+			if err := cmd.reload(); err != nil {
+				pluginPrint(fmt.Sprintf("Failed to reload configuration: %v", err), "ERROR")
+			}
+		}
+	}
+}
@@ -0,0 +1,604 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupModeFull        = "full"
+	backupModeIncremental = "incremental"
+
+	manifestSuffix = ".manifest.json"
+	patchExtension = ".patch.tar.gz"
+)
+
+// ChangeOp describes what happened to a single changeset entry relative to the parent backup
+type ChangeOp string
+
+const (
+	ChangeAdded    ChangeOp = "added"
+	ChangeModified ChangeOp = "modified"
+	ChangeDeleted  ChangeOp = "deleted"
+)
+
+// FileEntry records the fingerprint of a single file in a manifest, used to diff against the next backup
+type FileEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash"`
+}
+
+// ChangeEntry is a single change to be written into a patch layer for an incremental backup
+type ChangeEntry struct {
+	Path string   `json:"path"`
+	Op   ChangeOp `json:"op"`
+	Hash string   `json:"hash,omitempty"`
+}
+
+// BackupManifest records the full file tree fingerprint of a backup plus its place in the incremental chain
+type BackupManifest struct {
+	BackupID       string               `json:"backup_id"`
+	ParentBackupID string               `json:"parent_backup_id,omitempty"`
+	WorldName      string               `json:"world_name"`
+	Mode           string               `json:"mode"`
+	CreatedAt      time.Time            `json:"created_at"`
+	Files          map[string]FileEntry `json:"files"`
+	// ArchiveHash is the sha256 of this layer's archive (the archive file itself for a
+	// full backup, the patch layer for an incremental one), used by the restore flow in main() to
+	// detect silent storage corruption when Backup.Verify is enabled
+	ArchiveHash string `json:"archive_hash,omitempty"`
+}
+
+// hashFile computes the sha256 of a file's content, used to diff changes for incremental backups
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashArchiveSource is like hashFile, but path may be an s3/webdav/sftp remote URI, in which
+// case it hashes the stream returned by Get() directly without downloading the whole archive first
+func hashArchiveSource(ctx context.Context, path string) (string, error) {
+	if !isRemoteBackupURI(path) {
+		return hashFile(path)
+	}
+
+	backend, key, err := resolveStorageBackend(path)
+	if err != nil {
+		return "", err
+	}
+
+	rc, err := backend.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// snapshotWorldDir walks the world directory and fingerprints every file into a full file tree snapshot
+func snapshotWorldDir(worldDir string) (map[string]FileEntry, error) {
+	snapshot := make(map[string]FileEntry)
+
+	err := filepath.Walk(worldDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(worldDir, path)
+		if err != nil {
+			return err
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to hash file %s: %v", relPath, err)
+		}
+
+		snapshot[filepath.ToSlash(relPath)] = FileEntry{
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Hash:    hash,
+		}
+		return nil
+	})
+
+	return snapshot, err
+}
+
+// computeChangeset diffs the current world snapshot against the parent backup manifest to get the incremental changeset
+func computeChangeset(current map[string]FileEntry, parent *BackupManifest) []ChangeEntry {
+	var parentFiles map[string]FileEntry
+	if parent != nil {
+		parentFiles = parent.Files
+	}
+
+	var changes []ChangeEntry
+
+	for relPath, entry := range current {
+		if prev, ok := parentFiles[relPath]; !ok || prev.Hash != entry.Hash {
+			changes = append(changes, ChangeEntry{Path: relPath, Op: ChangeAdded, Hash: entry.Hash})
+			if ok {
+				changes[len(changes)-1].Op = ChangeModified
+			}
+		}
+	}
+
+	for relPath := range parentFiles {
+		if _, ok := current[relPath]; !ok {
+			changes = append(changes, ChangeEntry{Path: relPath, Op: ChangeDeleted})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// writePatchLayer packs added/modified files from the changeset into a tar.gz patch layer;
+// deleted entries carry no file content and only show up in the returned whiteouts list for the caller to write into the manifest
+func writePatchLayer(ctx context.Context, worldDir, patchPath string, changes []ChangeEntry) ([]string, error) {
+	out, err := os.Create(patchPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create patch file: %v", err)
+	}
+	defer out.Close()
+
+	gzWriter := gzip.NewWriter(out)
+	defer gzWriter.Close()
+
+	tarWriter := tar.NewWriter(gzWriter)
+	defer tarWriter.Close()
+
+	var whiteouts []string
+
+	for _, change := range changes {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if change.Op == ChangeDeleted {
+			whiteouts = append(whiteouts, change.Path)
+			continue
+		}
+
+		srcPath := filepath.Join(worldDir, filepath.FromSlash(change.Path))
+		info, err := os.Stat(srcPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat file %s: %v", change.Path, err)
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return nil, err
+		}
+		header.Name = change.Path
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return nil, err
+		}
+
+		file, err := os.Open(srcPath)
+		if err != nil {
+			return nil, err
+		}
+		_, copyErr := io.Copy(tarWriter, file)
+		file.Close()
+		if copyErr != nil {
+			return nil, copyErr
+		}
+	}
+
+	return whiteouts, nil
+}
+
+// writeManifest serializes the manifest and whiteouts list to sidecar files next to the backup
+func writeManifest(backupPath string, manifest *BackupManifest, whiteouts []string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %v", err)
+	}
+
+	if err := os.WriteFile(backupPath+manifestSuffix, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+
+	if len(whiteouts) > 0 {
+		whiteoutData, err := json.MarshalIndent(whiteouts, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize whiteouts: %v", err)
+		}
+		if err := os.WriteFile(backupPath+".whiteouts.json", whiteoutData, 0644); err != nil {
+			return fmt.Errorf("failed to write whiteouts: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// readManifest reads the manifest sidecar next to a backup; returns nil (not an error) when it is
+// missing, since full backups produced by older versions have no manifest. backupPath may be a
+// local path or an s3/webdav/sftp remote URI
+func readManifest(backupPath string) (*BackupManifest, error) {
+	// Chain-walking (resolveBackupChain and friends) happens before a request-level ctx exists;
+	// fall back to context.Background() here. Long-running downloads that actually need to respond
+	// to cancellation go through downloadToTempFile/Extract, which already carry the request ctx
+	data, err := readSidecarBytes(context.Background(), backupPath+manifestSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+
+	return &manifest, nil
+}
+
+// readWhiteouts reads the deletion list that goes with a patch layer. backupPath may be a local
+// path or an s3/webdav/sftp remote URI
+func readWhiteouts(backupPath string) ([]string, error) {
+	data, err := readSidecarBytes(context.Background(), backupPath+".whiteouts.json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read whiteouts: %v", err)
+	}
+
+	var whiteouts []string
+	if err := json.Unmarshal(data, &whiteouts); err != nil {
+		return nil, fmt.Errorf("failed to parse whiteouts: %v", err)
+	}
+
+	return whiteouts, nil
+}
+
+// incrementalChainDepth counts how many incremental layers must be walked back
+// from a manifest to reach the nearest full backup, used by backupCurrentWorld
+// to decide whether the chain has exceeded RetentionCount and needs capping
+func incrementalChainDepth(backupDir string, manifest *BackupManifest) (int, error) {
+	depth := 0
+	current := manifest
+	for current.Mode == backupModeIncremental {
+		if current.ParentBackupID == "" {
+			return depth, fmt.Errorf("incremental backup %s is missing its parent backup reference", current.BackupID)
+		}
+
+		parentPath := filepath.Join(backupDir, current.ParentBackupID)
+		parentManifest, err := readManifest(parentPath)
+		if err != nil {
+			return depth, err
+		}
+		if parentManifest == nil {
+			return depth, fmt.Errorf("could not find manifest for parent backup %s", current.ParentBackupID)
+		}
+
+		depth++
+		current = parentManifest
+	}
+	return depth, nil
+}
+
+// latestBackupForWorld finds the most recent backup for a world in the backup directory
+// (by manifest CreatedAt), to use as the parent of the next incremental backup
+func latestBackupForWorld(backupDir, worldName string) (string, *BackupManifest, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil, nil
+		}
+		return "", nil, err
+	}
+
+	var latestPath string
+	var latestManifest *BackupManifest
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		if len(entry.Name()) < len(manifestSuffix) || entry.Name()[len(entry.Name())-len(manifestSuffix):] != manifestSuffix {
+			continue
+		}
+
+		backupPath := filepath.Join(backupDir, entry.Name()[:len(entry.Name())-len(manifestSuffix)])
+		manifest, err := readManifest(backupPath)
+		if err != nil || manifest == nil {
+			continue
+		}
+		if manifest.WorldName != worldName {
+			continue
+		}
+
+		if latestManifest == nil || manifest.CreatedAt.After(latestManifest.CreatedAt) {
+			latestManifest = manifest
+			latestPath = backupPath
+		}
+	}
+
+	return latestPath, latestManifest, nil
+}
+
+// resolveBackupChain starts from any layer of an incremental patch, walks ParentBackupID back to the
+// nearest full backup, and returns the backup ID paths and their manifests ordered oldest full backup
+// first through the newest layer (the one the caller passed in). Shared by applyPatchChain and verifyArchiveChain
+func resolveBackupChain(backupPath string, manifest *BackupManifest) ([]string, []*BackupManifest, error) {
+	chain := []string{backupPath}
+	manifests := []*BackupManifest{manifest}
+
+	current := manifest
+	for current.Mode == backupModeIncremental {
+		if current.ParentBackupID == "" {
+			return nil, nil, fmt.Errorf("incremental backup %s is missing its parent backup reference", current.BackupID)
+		}
+
+		parentPath := filepath.Join(filepath.Dir(backupPath), current.ParentBackupID)
+		parentManifest, err := readManifest(parentPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if parentManifest == nil {
+			return nil, nil, fmt.Errorf("could not find manifest for parent backup %s", current.ParentBackupID)
+		}
+
+		chain = append(chain, parentPath)
+		manifests = append(manifests, parentManifest)
+		current = parentManifest
+	}
+
+	// chain/manifests are currently newest-to-oldest; reverse to oldest full backup first, newest patch last
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+		manifests[i], manifests[j] = manifests[j], manifests[i]
+	}
+
+	return chain, manifests, nil
+}
+
+// verifyArchiveChain recomputes the sha256 of every layer's archive (the archive file itself for a
+// full backup, the patch layer for an incremental one) before applying the incremental chain or
+// extracting a full backup, and compares it against the ArchiveHash recorded in its manifest. Used
+// when Backup.Verify is archive/per-file to catch storage-level corruption. Manifests produced by
+// older versions have no ArchiveHash field; that layer is skipped with a WARNING instead of an error.
+// headArchivePath is the newest layer's original, extension-carrying path (the one corresponding to
+// backupIDPath as passed by the caller); unlike a local path, a remote URI can't have its extension
+// probed via resolveBackupArchivePath, so the caller must supply it directly
+func verifyArchiveChain(ctx context.Context, headArchivePath, backupIDPath string, manifest *BackupManifest) error {
+	chain, manifests, err := resolveBackupChain(backupIDPath, manifest)
+	if err != nil {
+		return err
+	}
+
+	for i, layerPath := range chain {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		layerManifest := manifests[i]
+		if layerManifest.ArchiveHash == "" {
+			pluginPrint(fmt.Sprintf("backup layer %s has no archive_hash (likely produced by an older version), skipping its integrity check", layerManifest.BackupID), "WARNING")
+			continue
+		}
+
+		var archivePath string
+		switch {
+		case i == len(chain)-1 && isRemoteBackupURI(layerPath):
+			archivePath = headArchivePath
+		case i == 0:
+			archivePath = resolveBackupArchivePath(layerPath)
+		default:
+			archivePath = layerPath + patchExtension
+		}
+
+		hash, err := hashArchiveSource(ctx, archivePath)
+		if err != nil {
+			return fmt.Errorf("failed to hash archive file %s: %v", archivePath, err)
+		}
+		if hash != layerManifest.ArchiveHash {
+			return fmt.Errorf("integrity check failed for archive file %s, the backup may be corrupted", archivePath)
+		}
+	}
+
+	return nil
+}
+
+// verifyExtractedFiles checks the already-extracted files under destDir against the per-file hashes
+// recorded in the manifest, used when Backup.Verify is per-file to catch individual files silently
+// corrupted by storage before the existing world directory is deleted
+func verifyExtractedFiles(destDir string, expected map[string]FileEntry) error {
+	for relPath, entry := range expected {
+		fullPath := filepath.Join(destDir, filepath.FromSlash(relPath))
+
+		hash, err := hashFile(fullPath)
+		if err != nil {
+			return fmt.Errorf("failed to verify file %s (missing or unreadable): %v", relPath, err)
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf("hash check failed for file %s, the backup may be corrupted", relPath)
+		}
+	}
+
+	return nil
+}
+
+// applyPatchChain starts from any layer of an incremental patch, walks ParentBackupID back to the
+// nearest full backup, then extracts the full backup followed by each patch layer in order onto destDir.
+// onlyGlob, when non-empty, restricts what gets written to paths matching the glob, for -only subset restore
+func applyPatchChain(ctx context.Context, backupPath string, manifest *BackupManifest, destDir, onlyGlob string) error {
+	chain, _, err := resolveBackupChain(backupPath, manifest)
+	if err != nil {
+		return err
+	}
+
+	archiver := newArchiver(externalExe7zPath(currentConfig().Compression))
+
+	basePath := resolveBackupArchivePath(chain[0])
+	pluginPrint(fmt.Sprintf("Extracting the full backup at the base of the incremental chain: %s", basePath), "INFO")
+	if err := archiver.Extract(ctx, basePath, destDir, onlyGlob, newThrottledProgress()); err != nil {
+		return fmt.Errorf("failed to extract base full backup: %v", err)
+	}
+
+	for i := 1; i < len(chain); i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		patchPath := chain[i] + patchExtension
+		pluginPrint(fmt.Sprintf("Applying incremental patch layer: %s", patchPath), "INFO")
+
+		if err := extractPatchLayer(ctx, patchPath, destDir, onlyGlob); err != nil {
+			return fmt.Errorf("failed to apply patch layer %s: %v", patchPath, err)
+		}
+
+		whiteouts, err := readWhiteouts(chain[i])
+		if err != nil {
+			return err
+		}
+		for _, relPath := range whiteouts {
+			targetPath, err := safeExtractEntryPath(destDir, relPath)
+			if err != nil {
+				return fmt.Errorf("whiteout entry path escapes destDir: %v", err)
+			}
+			if err := os.RemoveAll(targetPath); err != nil {
+				return fmt.Errorf("failed to apply whiteout delete %s: %v", relPath, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// extractPatchLayer overwrites files in destDir with the contents of a single tar.gz patch layer,
+// skipping entries whose relative path doesn't match onlyGlob when it is non-empty. patchPath can
+// be a local path or an s3/webdav/sftp remote URI — gzip.NewReader only needs an io.Reader, no
+// seeking, so a remote patch layer can be decompressed on the fly without first downloading the
+// whole thing to a local temp file the way the external-7z/chunked-zstd paths in archive.go do
+func extractPatchLayer(ctx context.Context, patchPath, destDir, onlyGlob string) error {
+	var file io.ReadCloser
+	if isRemoteBackupURI(patchPath) {
+		backend, key, err := resolveStorageBackend(patchPath)
+		if err != nil {
+			return err
+		}
+		rc, err := backend.Get(ctx, key)
+		if err != nil {
+			return fmt.Errorf("failed to read patch layer from remote storage: %v", err)
+		}
+		file = rc
+	} else {
+		f, err := os.Open(patchPath)
+		if err != nil {
+			return err
+		}
+		file = f
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if onlyGlob != "" {
+			matched, err := filepath.Match(onlyGlob, header.Name)
+			if err != nil {
+				return fmt.Errorf("invalid -only match pattern: %v", err)
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		targetPath, err := safeExtractEntryPath(destDir, header.Name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return err
+		}
+
+		outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+		if err != nil {
+			return err
+		}
+		_, copyErr := io.Copy(outFile, tarReader)
+		outFile.Close()
+		if copyErr != nil {
+			return copyErr
+		}
+	}
+
+	return nil
+}
+
+// backupIDPathFromFile strips known archive/patch suffixes to get the backup's ID path
+// (i.e. the manifest sidecar path with .manifest.json removed), used to look up manifests by path
+func backupIDPathFromFile(path string) string {
+	for _, ext := range []string{patchExtension, ".tar.gz", ".tar.xz", ".tar.zst", ".zip", ".7z"} {
+		if strings.HasSuffix(path, ext) {
+			return strings.TrimSuffix(path, ext)
+		}
+	}
+	return path
+}
+
+// resolveBackupArchivePath finds the actual archive file next to a full backup's manifest
+// by trying the conventional extensions in the same directory
+func resolveBackupArchivePath(backupIDPath string) string {
+	for _, ext := range []string{".zip", ".7z", ".tar.gz", ".tar.xz", ".tar.zst"} {
+		if _, err := os.Stat(backupIDPath + ext); err == nil {
+			return backupIDPath + ext
+		}
+	}
+	return backupIDPath
+}
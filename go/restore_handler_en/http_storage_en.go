@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// httpDownloadMaxAttempts is the max number of attempts downloadWithResume makes, counting
+// the first attempt and every retry after an interrupted transfer
+const httpDownloadMaxAttempts = 3
+
+// httpStorageBackend pulls an archive from a plain HTTP(S) server. It is read-only and does not
+// support Put/List/Delete — the restore tool has no general-purpose HTTP upload/listing protocol
+// to assume, so this kind of remote URI is only used as the archive specified via -backup
+type httpStorageBackend struct {
+	client *http.Client
+	cfg    HTTPConfig
+}
+
+// resolveHTTPBackend validates that uri is a well-formed http(s):// URI; credentials come from
+// pluginConfig.Backup.Storage.HTTP. Unlike s3/webdav/sftp, the "key" here is the full URI itself,
+// since HTTP has no bucket/root-directory prefix concept
+func resolveHTTPBackend(uri string) (StorageBackend, string, error) {
+	if _, err := url.Parse(uri); err != nil {
+		return nil, "", fmt.Errorf("failed to parse HTTP URI: %v", err)
+	}
+	return &httpStorageBackend{client: &http.Client{}, cfg: pluginConfig.Backup.Storage.HTTP}, uri, nil
+}
+
+// authorize attaches auth info to the request: BearerToken wins if set, otherwise Basic auth;
+// when the config is empty, the matching environment variable is used instead
+func (b *httpStorageBackend) authorize(req *http.Request) {
+	token := b.cfg.BearerToken
+	if token == "" {
+		token = os.Getenv(envHTTPBearerToken)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return
+	}
+
+	username := b.cfg.Username
+	if username == "" {
+		username = os.Getenv(envHTTPUsername)
+	}
+	password := b.cfg.Password
+	if password == "" {
+		password = os.Getenv(envHTTPPassword)
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+}
+
+func (b *httpStorageBackend) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HTTP request: %v", err)
+	}
+	b.authorize(req)
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request HTTP archive: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP request returned non-200 status: %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (b *httpStorageBackend) Put(ctx context.Context, name string, r io.Reader) error {
+	return errors.New("http backup source is read-only, upload is not supported")
+}
+
+func (b *httpStorageBackend) List(ctx context.Context) ([]ObjectInfo, error) {
+	return nil, errors.New("http backup source does not support listing, specify the full URI directly in -backup")
+}
+
+func (b *httpStorageBackend) Delete(ctx context.Context, name string) error {
+	return errors.New("http backup source is read-only, delete is not supported")
+}
+
+// downloadWithResume downloads the archive at url to destPath. If the transfer fails partway
+// through (network hiccup, connection reset by the peer, etc.), it resumes with a
+// Range: bytes=<written>- request based on the bytes already written, making at most
+// httpDownloadMaxAttempts attempts including the first, instead of re-pulling the whole archive from byte zero every
+// time — this matters a lot for large cold-storage downloads over the public internet. If the
+// server doesn't honor Range (it still returns 200 instead of 206 when bytes already exist), the
+// code treats that as resume-unsupported, clears the local partial download, and starts over
+func (b *httpStorageBackend) downloadWithResume(ctx context.Context, url, destPath string, progress Progress) error {
+	var lastErr error
+	var doneBytes int64
+
+	for attempt := 0; attempt < httpDownloadMaxAttempts; attempt++ {
+		if attempt > 0 {
+			pluginPrint(fmt.Sprintf("HTTP download interrupted, retrying resume from byte %d in %d seconds: %v", doneBytes, httpRetryBackoffSeconds, lastErr), "WARNING")
+			time.Sleep(time.Duration(httpRetryBackoffSeconds) * time.Second)
+		}
+
+		existing, err := fileSize(destPath)
+		if err != nil {
+			return fmt.Errorf("failed to read local temp file size: %v", err)
+		}
+		doneBytes = existing
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("failed to build HTTP request: %v", err)
+		}
+		b.authorize(req)
+		if existing > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", existing))
+		}
+
+		resp, err := b.client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		resumed := resp.StatusCode == http.StatusPartialContent
+		if existing > 0 && !resumed {
+			// the server doesn't support Range resume, fall back to a full re-download
+			pluginPrint("remote server does not support Range resume, clearing local partial download and starting over", "WARNING")
+			existing = 0
+			doneBytes = 0
+		}
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusNotFound {
+				return fmt.Errorf("%s: %w", url, os.ErrNotExist)
+			}
+			return fmt.Errorf("HTTP request returned non-200/206 status: %d", resp.StatusCode)
+		}
+
+		total := int64(-1)
+		if resp.ContentLength >= 0 {
+			total = existing + resp.ContentLength
+		}
+
+		flags := os.O_CREATE | os.O_WRONLY
+		if resumed {
+			flags |= os.O_APPEND
+		} else {
+			flags |= os.O_TRUNC
+		}
+		out, err := os.OpenFile(destPath, flags, 0644)
+		if err != nil {
+			resp.Body.Close()
+			return fmt.Errorf("failed to open local temp file: %v", err)
+		}
+
+		reader := io.Reader(resp.Body)
+		if progress != nil {
+			done := doneBytes
+			reader = &countingReader{r: resp.Body, done: &done, total: total, path: url, progress: progress}
+		}
+
+		_, copyErr := io.Copy(out, reader)
+		out.Close()
+		resp.Body.Close()
+
+		if copyErr == nil {
+			return nil
+		}
+		lastErr = copyErr
+	}
+
+	return fmt.Errorf("download still failed after multiple attempts: %v", lastErr)
+}
+
+// httpRetryBackoffSeconds is the wait time between two resume attempts in downloadWithResume
+const httpRetryBackoffSeconds = 3
+
+// fileSize returns the size of the file at path, returning 0 instead of an error when the file
+// doesn't exist, so downloadWithResume can tell "first download" apart from "resuming an
+// existing partial file"
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
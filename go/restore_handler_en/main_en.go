@@ -1,20 +1,19 @@
-
 package main
 
 import (
-	"archive/tar"
-	"compress/gzip"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fatih/color"
@@ -27,40 +26,141 @@ const (
 	defaultMaxWorkers = 4
 )
 
+const (
+	verifyNone    = "none"
+	verifyArchive = "archive"
+	verifyPerFile = "per-file"
+)
+
 // Config struct definition
 type GlobalConfig struct {
 	Debug      bool `json:"debug"`
 	MaxWorkers int  `json:"max_workers"`
 }
 
+type RCONConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Password string `json:"password"`
+}
+
+type RestartServerConfig struct {
+	Status          bool   `json:"status"`
+	WaitTimeS       int    `json:"wait_time_s"`
+	StartScriptPath string `json:"start_script_path"`
+	// StopCommand, when non-empty, makes the restore try to request a
+	// graceful shutdown from a still-running server over a named pipe
+	// (e.g. "stop"). When RCON.Host is non-empty, RCON is tried first instead,
+	// since RCON doesn't require the server to have been started by this plugin
+	StopCommand string     `json:"stop_command"`
+	RCON        RCONConfig `json:"rcon"`
+	// StopTimeoutS is how long (in seconds) to wait for the server to exit on its
+	// own after a graceful shutdown request before sending a platform-specific
+	// forced termination signal (SIGTERM on Unix, CTRL_BREAK on Windows) and
+	// continuing to poll. A value of 0 keeps the old behavior: poll forever.
+	// Applies only while the PID recorded in the pid file still exists; the process-name
+	// scan fallback sends no termination signal and only polls
+	StopTimeoutS int `json:"stop_timeout_s"`
+	// HealthCheckTimeoutS is how long (in seconds) to wait for the server process to
+	// actually come back up after being started; 0 defaults to 30 seconds. If the
+	// window elapses with no process detected, the restore flow treats this restart
+	// as failed and automatically swaps the rollback directory back into place
+	HealthCheckTimeoutS int `json:"health_check_timeout_s"`
+}
+
 type RestoreConfig struct {
 	Config struct {
-		BackupOldWorldBeforeRestore bool `json:"backup_old_world_before_restore"`
-		Debug                       bool `json:"debug"`
-		RestartServer               struct {
-			Status          bool   `json:"status"`
-			WaitTimeS       int    `json:"wait_time_s"`
-			StartScriptPath string `json:"start_script_path"`
-		} `json:"restart_server"`
+		BackupOldWorldBeforeRestore bool                `json:"backup_old_world_before_restore"`
+		Debug                       bool                `json:"debug"`
+		RestartServer               RestartServerConfig `json:"restart_server"`
+		// RollbackKeepCount caps how many <world name>.rollback-<timestamp> directories
+		// are kept under worlds/; cleanup runs after a restore succeeds and the server
+		// passes its health check. 0 disables automatic cleanup entirely
+		RollbackKeepCount int `json:"rollback_keep_count"`
 	} `json:"config"`
+	// Notifications configures the HTTP status callback fired when a restore finishes,
+	// sent to an external panel/bot; an empty URL means the feature is disabled
+	Notifications NotificationConfig `json:"Notifications"`
 }
 
 type CompressionFormat struct {
 	Extension    string   `json:"extension"`
 	CompressArgs []string `json:"compress_args"`
 	ExtractArgs  []string `json:"extract_args"`
+	// Backend, when non-empty, overrides the compression implementation for this
+	// format; only "pgzip" (klauspost/pgzip parallel gzip) currently has an effect,
+	// empty falls back to the default single-threaded archiver/v4 path
+	Backend string `json:"backend"`
+	// Level and NumCPU are only used when Backend is "pgzip", for compression level
+	// and worker count respectively; 0 falls back to pgzip.DefaultCompression and
+	// globalConfig.MaxWorkers respectively
+	Level  int `json:"level"`
+	NumCPU int `json:"num_cpu"`
 }
 
 type CompressionConfig struct {
-	Method    string                       `json:"method"`
-	Exe7zPath string                       `json:"exe_7z_path"`
-	Formats   map[string]CompressionFormat `json:"formats"`
+	Method string `json:"method"`
+	// Exe7zPath only takes effect when UseExternal7z is true, pointing at the
+	// external 7z executable; by default compression/extraction go through
+	// the built-in pure-Go implementation instead of requiring this binary
+	Exe7zPath     string                       `json:"exe_7z_path"`
+	UseExternal7z bool                         `json:"use_external_7z"`
+	Formats       map[string]CompressionFormat `json:"formats"`
+	// Level maps to zstd's EncoderLevel (1=fastest, 4=best compression); 0 uses
+	// klauspost/compress's default level. Currently only affects chunked tar.zst —
+	// these two fields just add two tuning knobs to the existing chunked zstd path;
+	// the archive format itself is still the handful of built-in formats switched on
+	// by filename/file header in archive.go (zip/tar.gz/tar.xz/tar.zst/7z), not a
+	// registry that lets callers register new ArchiveFormat implementations
+	Level int `json:"level"`
+	// Threads overrides the number of concurrent frame encoders used for chunked
+	// zstd compression; 0 falls back to globalConfig.MaxWorkers
+	Threads int `json:"threads"`
+}
+
+// BackupConfig controls whether the pre-restore auto backup (backupCurrentWorld) runs full or incremental
+type BackupConfig struct {
+	Mode string `json:"mode"` // "full" or "incremental", defaults to full
+	// RetentionCount only truncates the incremental chain by length (number of links), forcing
+	// a full backup once that cap is hit. It is not a time-based FullInterval, and the per-file
+	// diff underneath is still chunk0-2's whole-file SHA-256 comparison, not hashing over
+	// fixed-size content chunks (the docker/archive ChangesDirs-style approach) — both of those
+	// were part of this backlog entry's original ask and were not implemented
+	RetentionCount int `json:"retention_count"`
+	// Verify controls the integrity check level applied when restoring: none (default, no
+	// check) / archive (hash-check the whole layer archive) / per-file (additionally
+	// hash-check each extracted file). A mismatch aborts the restore without deleting the
+	// existing world directory
+	Verify string `json:"verify"`
+	// Storage configures the connection info for remote backup storage. Its credentials are
+	// used when -backup is given an s3://, webdav://, sftp:// or http(s):// remote URI; when
+	// Type is "s3", "webdav" or "sftp", backupCurrentWorld also pushes each new backup to that
+	// storage. An http(s):// URI is read-only (backupCurrentWorld does not push to that type)
+	Storage StorageConfig `json:"storage"`
+	// Retention configures the generational retention policy applied after backupCurrentWorld
+	// writes a new archive
+	Retention RetentionConfig `json:"retention"`
+}
+
+// RetentionConfig controls the generational (grandfather-father-son) retention policy: backups
+// are bucketed by day/week/month/year, and the newest N in each bucket are kept, on top of
+// KeepLast unconditionally-kept most recent backups. A field of 0 means that tier keeps nothing
+// extra. When MaxTotalSizeGB is non-zero, backups are additionally deleted oldest-first after the
+// generational pass until the local backup directory's total size is back under the cap
+type RetentionConfig struct {
+	KeepLast       int     `json:"keep_last"`
+	KeepDaily      int     `json:"keep_daily"`
+	KeepWeekly     int     `json:"keep_weekly"`
+	KeepMonthly    int     `json:"keep_monthly"`
+	KeepYearly     int     `json:"keep_yearly"`
+	MaxTotalSizeGB float64 `json:"max_total_size_gb"`
 }
 
 type PluginConfig struct {
 	Compression CompressionConfig `json:"Compression"`
 	MaxWorkers  int               `json:"max_workers"`
 	Restore     RestoreConfig
+	Backup      BackupConfig `json:"Backup"`
 }
 
 // RestoreInfo struct
@@ -73,17 +173,33 @@ type RestoreInfo struct {
 // Global variables
 var (
 	globalConfig GlobalConfig
-	pluginConfig PluginConfig
-	restoreInfo  RestoreInfo
-	logger       *log.Logger
-	logFile      *os.File
-	cyan         = color.New(color.FgCyan).SprintFunc()
-	white        = color.New(color.FgWhite).SprintFunc()
-	yellow       = color.New(color.FgYellow).SprintFunc()
-	red          = color.New(color.FgRed).SprintFunc()
-	green        = color.New(color.FgGreen).SprintFunc()
+	// pluginConfig should only be accessed while holding pluginConfigMu: loadConfig builds
+	// the whole new config before swapping it in one shot, instead of mutating fields in
+	// place, and readers go through currentConfig() to get a snapshot that's internally
+	// consistent at that instant, never a "half-swapped" config. This matters in daemon
+	// mode, where a reload goroutine triggered by SIGHUP/config file changes can run
+	// concurrently with the cron-scheduled backupCurrentWorld
+	pluginConfig   PluginConfig
+	pluginConfigMu sync.RWMutex
+	restoreInfo    RestoreInfo
+	logger         *log.Logger
+	logFile        *os.File
+	cyan           = color.New(color.FgCyan).SprintFunc()
+	white          = color.New(color.FgWhite).SprintFunc()
+	yellow         = color.New(color.FgYellow).SprintFunc()
+	red            = color.New(color.FgRed).SprintFunc()
+	green          = color.New(color.FgGreen).SprintFunc()
 )
 
+// currentConfig returns a snapshot (value copy) of pluginConfig as it stands right now.
+// Concurrent readers should use this instead of reading pluginConfig directly, especially
+// on code paths that may run concurrently with a reload in daemon mode
+func currentConfig() PluginConfig {
+	pluginConfigMu.RLock()
+	defer pluginConfigMu.RUnlock()
+	return pluginConfig
+}
+
 // pluginPrint custom log output
 func pluginPrint(text string, level string) {
 	// If it's DEBUG level and DEBUG mode is not enabled, don't output
@@ -157,26 +273,35 @@ func setupLogging(serverDir string) error {
 	return nil
 }
 
-// loadConfig load configuration file
-func loadConfig(serverDir string) error {
-	// Try multiple possible configuration file paths
+// resolveConfigPath finds which of the conventional config paths actually exists, returning an
+// empty string if none do. loadConfig and daemon mode's watchConfigFile share this detection
+// logic so reload watches the same file loadConfig would have used
+func resolveConfigPath(serverDir string) string {
 	possiblePaths := []string{
 		filepath.Join(serverDir, "plugins", "EasyBackuper", "config", "EasyBackuper.json"),
 		filepath.Join(".", "plugins", "EasyBackuper", "config", "EasyBackuper.json"),
-		filepath.Join(".", "plugins", "EasyBackuper", "config", "EasyBackuper.json"),
 	}
 
-	var configPath string
 	for _, path := range possiblePaths {
 		if _, err := os.Stat(path); err == nil {
-			configPath = path
-			break
+			return path
 		}
 	}
+	return ""
+}
+
+// loadConfig loads the configuration file. Every call fills defaults and the parsed file
+// contents into a local cfg variable first, and only once cfg is fully built does it swap
+// cfg into the global pluginConfig in one shot under pluginConfigMu, instead of mutating the
+// global field by field. That makes repeated calls against the same file idempotent, so
+// daemon mode's SIGHUP/file-change reload can reuse it safely and concurrent readers never
+// observe a half-swapped config
+func loadConfig(serverDir string) error {
+	configPath := resolveConfigPath(serverDir)
 
 	if configPath == "" {
 		pluginPrint("All possible configuration file paths do not exist, using default configuration", "WARNING")
-		pluginConfig = PluginConfig{
+		cfg := PluginConfig{
 			Compression: CompressionConfig{
 				Method:    "zip",
 				Exe7zPath: "./plugins/EasyBackuper/7za.exe",
@@ -185,21 +310,26 @@ func loadConfig(serverDir string) error {
 			MaxWorkers: defaultMaxWorkers,
 		}
 		// Initialize default formats
-		pluginConfig.Compression.Formats["7z"] = CompressionFormat{
+		cfg.Compression.Formats["7z"] = CompressionFormat{
 			Extension:    ".7z",
 			CompressArgs: []string{"a", "-t7z", "-mx=5"},
 			ExtractArgs:  []string{"x", "-y"},
 		}
-		pluginConfig.Compression.Formats["zip"] = CompressionFormat{
+		cfg.Compression.Formats["zip"] = CompressionFormat{
 			Extension:    ".zip",
 			CompressArgs: []string{"a", "-tzip", "-mx=5"},
 			ExtractArgs:  []string{"x", "-y"},
 		}
-		pluginConfig.Compression.Formats["tar"] = CompressionFormat{
+		cfg.Compression.Formats["tar"] = CompressionFormat{
 			Extension:    ".tar.gz",
 			CompressArgs: []string{"a", "-ttar", "-mx=5"},
 			ExtractArgs:  []string{"x", "-y"},
 		}
+		cfg.Backup.Mode = backupModeFull
+		cfg.Backup.Verify = verifyNone
+		pluginConfigMu.Lock()
+		pluginConfig = cfg
+		pluginConfigMu.Unlock()
 		return nil
 	}
 
@@ -218,7 +348,7 @@ func loadConfig(serverDir string) error {
 	}
 
 	// Initialize default configuration
-	pluginConfig = PluginConfig{
+	cfg := PluginConfig{
 		Compression: CompressionConfig{
 			Method:    "zip",
 			Exe7zPath: "./plugins/EasyBackuper/7za.exe",
@@ -227,17 +357,17 @@ func loadConfig(serverDir string) error {
 		MaxWorkers: defaultMaxWorkers,
 	}
 	// Initialize default formats
-	pluginConfig.Compression.Formats["7z"] = CompressionFormat{
+	cfg.Compression.Formats["7z"] = CompressionFormat{
 		Extension:    ".7z",
 		CompressArgs: []string{"a", "-t7z", "-mx=5"},
 		ExtractArgs:  []string{"x", "-y"},
 	}
-	pluginConfig.Compression.Formats["zip"] = CompressionFormat{
+	cfg.Compression.Formats["zip"] = CompressionFormat{
 		Extension:    ".zip",
 		CompressArgs: []string{"a", "-tzip", "-mx=5"},
 		ExtractArgs:  []string{"x", "-y"},
 	}
-	pluginConfig.Compression.Formats["tar"] = CompressionFormat{
+	cfg.Compression.Formats["tar"] = CompressionFormat{
 		Extension:    ".tar.gz",
 		CompressArgs: []string{"a", "-ttar", "-mx=5"},
 		ExtractArgs:  []string{"x", "-y"},
@@ -246,14 +376,23 @@ func loadConfig(serverDir string) error {
 	// Set plugin configuration
 	if compressionData, ok := config["Compression"].(map[string]interface{}); ok {
 		if method, ok := compressionData["method"].(string); ok {
-			pluginConfig.Compression.Method = method
+			cfg.Compression.Method = method
 		}
 		if exe7zPath, ok := compressionData["exe_7z_path"].(string); ok {
-			pluginConfig.Compression.Exe7zPath = exe7zPath
+			cfg.Compression.Exe7zPath = exe7zPath
+		}
+		if useExternal7z, ok := compressionData["use_external_7z"].(bool); ok {
+			cfg.Compression.UseExternal7z = useExternal7z
+		}
+		if level, ok := compressionData["level"].(float64); ok {
+			cfg.Compression.Level = int(level)
+		}
+		if threads, ok := compressionData["threads"].(float64); ok {
+			cfg.Compression.Threads = int(threads)
 		}
 		// Ensure Formats map is initialized
-		if pluginConfig.Compression.Formats == nil {
-			pluginConfig.Compression.Formats = make(map[string]CompressionFormat)
+		if cfg.Compression.Formats == nil {
+			cfg.Compression.Formats = make(map[string]CompressionFormat)
 		}
 		if formatsData, ok := compressionData["formats"].(map[string]interface{}); ok {
 			for formatName, formatData := range formatsData {
@@ -276,15 +415,24 @@ func loadConfig(serverDir string) error {
 							}
 						}
 					}
-					pluginConfig.Compression.Formats[formatName] = format
+					if backend, ok := formatMap["backend"].(string); ok {
+						format.Backend = backend
+					}
+					if level, ok := formatMap["level"].(float64); ok {
+						format.Level = int(level)
+					}
+					if numCPU, ok := formatMap["num_cpu"].(float64); ok {
+						format.NumCPU = int(numCPU)
+					}
+					cfg.Compression.Formats[formatName] = format
 				}
 			}
 		}
 	}
 	if maxWorkers, ok := config["max_workers"].(float64); ok {
-		pluginConfig.MaxWorkers = int(maxWorkers)
+		cfg.MaxWorkers = int(maxWorkers)
 	} else {
-		pluginConfig.MaxWorkers = defaultMaxWorkers
+		cfg.MaxWorkers = defaultMaxWorkers
 	}
 
 	// Parse Restore configuration
@@ -294,23 +442,168 @@ func loadConfig(serverDir string) error {
 				globalConfig.Debug = debugVal
 			}
 			if backupOldWorld, ok := configData["backup_old_world_before_restore"].(bool); ok {
-				pluginConfig.Restore.Config.BackupOldWorldBeforeRestore = backupOldWorld
+				cfg.Restore.Config.BackupOldWorldBeforeRestore = backupOldWorld
+			}
+			if rollbackKeepCount, ok := configData["rollback_keep_count"].(float64); ok {
+				cfg.Restore.Config.RollbackKeepCount = int(rollbackKeepCount)
 			}
 			if restartServer, ok := configData["restart_server"].(map[string]interface{}); ok {
 				if status, ok := restartServer["status"].(bool); ok {
-					pluginConfig.Restore.Config.RestartServer.Status = status
+					cfg.Restore.Config.RestartServer.Status = status
 				}
 				if waitTime, ok := restartServer["wait_time_s"].(float64); ok {
-					pluginConfig.Restore.Config.RestartServer.WaitTimeS = int(waitTime)
+					cfg.Restore.Config.RestartServer.WaitTimeS = int(waitTime)
 				}
 				if scriptPath, ok := restartServer["start_script_path"].(string); ok {
-					pluginConfig.Restore.Config.RestartServer.StartScriptPath = scriptPath
+					cfg.Restore.Config.RestartServer.StartScriptPath = scriptPath
+				}
+				if stopCommand, ok := restartServer["stop_command"].(string); ok {
+					cfg.Restore.Config.RestartServer.StopCommand = stopCommand
+				}
+				if stopTimeout, ok := restartServer["stop_timeout_s"].(float64); ok {
+					cfg.Restore.Config.RestartServer.StopTimeoutS = int(stopTimeout)
+				}
+				if healthCheckTimeout, ok := restartServer["health_check_timeout_s"].(float64); ok {
+					cfg.Restore.Config.RestartServer.HealthCheckTimeoutS = int(healthCheckTimeout)
+				}
+				if rconData, ok := restartServer["rcon"].(map[string]interface{}); ok {
+					if host, ok := rconData["host"].(string); ok {
+						cfg.Restore.Config.RestartServer.RCON.Host = host
+					}
+					if port, ok := rconData["port"].(float64); ok {
+						cfg.Restore.Config.RestartServer.RCON.Port = int(port)
+					}
+					if password, ok := rconData["password"].(string); ok {
+						cfg.Restore.Config.RestartServer.RCON.Password = password
+					}
 				}
 			}
 		}
+		if notificationsData, ok := restoreData["Notifications"].(map[string]interface{}); ok {
+			if url, ok := notificationsData["url"].(string); ok {
+				cfg.Restore.Notifications.URL = url
+			}
+			if secret, ok := notificationsData["secret"].(string); ok {
+				cfg.Restore.Notifications.Secret = secret
+			}
+			if attempts, ok := notificationsData["attempts"].(float64); ok {
+				cfg.Restore.Notifications.Attempts = int(attempts)
+			}
+			if backoffS, ok := notificationsData["backoff_s"].(float64); ok {
+				cfg.Restore.Notifications.BackoffS = int(backoffS)
+			}
+			if timeoutS, ok := notificationsData["timeout_s"].(float64); ok {
+				cfg.Restore.Notifications.TimeoutS = int(timeoutS)
+			}
+		}
 	}
 
-	globalConfig.MaxWorkers = pluginConfig.MaxWorkers
+	// Parse Backup config
+	cfg.Backup.Mode = backupModeFull
+	if backupData, ok := config["Backup"].(map[string]interface{}); ok {
+		if mode, ok := backupData["mode"].(string); ok {
+			cfg.Backup.Mode = mode
+		}
+		if retentionCount, ok := backupData["retention_count"].(float64); ok {
+			cfg.Backup.RetentionCount = int(retentionCount)
+		}
+		if verify, ok := backupData["verify"].(string); ok {
+			cfg.Backup.Verify = verify
+		}
+		if storageData, ok := backupData["storage"].(map[string]interface{}); ok {
+			if storageType, ok := storageData["type"].(string); ok {
+				cfg.Backup.Storage.Type = storageType
+			}
+			if s3Data, ok := storageData["s3"].(map[string]interface{}); ok {
+				if region, ok := s3Data["region"].(string); ok {
+					cfg.Backup.Storage.S3.Region = region
+				}
+				if endpoint, ok := s3Data["endpoint"].(string); ok {
+					cfg.Backup.Storage.S3.Endpoint = endpoint
+				}
+				if bucket, ok := s3Data["bucket"].(string); ok {
+					cfg.Backup.Storage.S3.Bucket = bucket
+				}
+				if accessKeyID, ok := s3Data["access_key_id"].(string); ok {
+					cfg.Backup.Storage.S3.AccessKeyID = accessKeyID
+				}
+				if secretAccessKey, ok := s3Data["secret_access_key"].(string); ok {
+					cfg.Backup.Storage.S3.SecretAccessKey = secretAccessKey
+				}
+				if usePathStyle, ok := s3Data["use_path_style"].(bool); ok {
+					cfg.Backup.Storage.S3.UsePathStyle = usePathStyle
+				}
+			}
+			if webdavData, ok := storageData["webdav"].(map[string]interface{}); ok {
+				if url, ok := webdavData["url"].(string); ok {
+					cfg.Backup.Storage.WebDAV.URL = url
+				}
+				if username, ok := webdavData["username"].(string); ok {
+					cfg.Backup.Storage.WebDAV.Username = username
+				}
+				if password, ok := webdavData["password"].(string); ok {
+					cfg.Backup.Storage.WebDAV.Password = password
+				}
+			}
+			if sftpData, ok := storageData["sftp"].(map[string]interface{}); ok {
+				if host, ok := sftpData["host"].(string); ok {
+					cfg.Backup.Storage.SFTP.Host = host
+				}
+				if port, ok := sftpData["port"].(float64); ok {
+					cfg.Backup.Storage.SFTP.Port = int(port)
+				}
+				if user, ok := sftpData["user"].(string); ok {
+					cfg.Backup.Storage.SFTP.User = user
+				}
+				if password, ok := sftpData["password"].(string); ok {
+					cfg.Backup.Storage.SFTP.Password = password
+				}
+				if privateKeyPath, ok := sftpData["private_key_path"].(string); ok {
+					cfg.Backup.Storage.SFTP.PrivateKeyPath = privateKeyPath
+				}
+			}
+			if httpData, ok := storageData["http"].(map[string]interface{}); ok {
+				if username, ok := httpData["username"].(string); ok {
+					cfg.Backup.Storage.HTTP.Username = username
+				}
+				if password, ok := httpData["password"].(string); ok {
+					cfg.Backup.Storage.HTTP.Password = password
+				}
+				if bearerToken, ok := httpData["bearer_token"].(string); ok {
+					cfg.Backup.Storage.HTTP.BearerToken = bearerToken
+				}
+			}
+		}
+		if retentionData, ok := backupData["retention"].(map[string]interface{}); ok {
+			if keepLast, ok := retentionData["keep_last"].(float64); ok {
+				cfg.Backup.Retention.KeepLast = int(keepLast)
+			}
+			if keepDaily, ok := retentionData["keep_daily"].(float64); ok {
+				cfg.Backup.Retention.KeepDaily = int(keepDaily)
+			}
+			if keepWeekly, ok := retentionData["keep_weekly"].(float64); ok {
+				cfg.Backup.Retention.KeepWeekly = int(keepWeekly)
+			}
+			if keepMonthly, ok := retentionData["keep_monthly"].(float64); ok {
+				cfg.Backup.Retention.KeepMonthly = int(keepMonthly)
+			}
+			if keepYearly, ok := retentionData["keep_yearly"].(float64); ok {
+				cfg.Backup.Retention.KeepYearly = int(keepYearly)
+			}
+			if maxTotalSizeGB, ok := retentionData["max_total_size_gb"].(float64); ok {
+				cfg.Backup.Retention.MaxTotalSizeGB = maxTotalSizeGB
+			}
+		}
+	}
+	if cfg.Backup.Verify == "" {
+		cfg.Backup.Verify = verifyNone
+	}
+
+	globalConfig.MaxWorkers = cfg.MaxWorkers
+
+	pluginConfigMu.Lock()
+	pluginConfig = cfg
+	pluginConfigMu.Unlock()
 
 	pluginPrint(fmt.Sprintf("Successfully loaded configuration file: %s", configPath), "SUCCESS")
 	pluginPrint(fmt.Sprintf("DEBUG mode: %v", globalConfig.Debug), "INFO")
@@ -319,8 +612,13 @@ func loadConfig(serverDir string) error {
 	return nil
 }
 
-// copyFileWithProgress copy file
-func copyFileWithProgress(src, dst string) error {
+// copyFileWithProgress copy file; doneBytes is the byte counter shared across the whole
+// directory copy so copyDirWithProgress can report aggregate progress
+func copyFileWithProgress(ctx context.Context, src, dst string, doneBytes *int64, totalBytes int64, progress Progress) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	pluginPrint(fmt.Sprintf("Copying file:%s --> %s", fmt.Sprint(src), fmt.Sprint(dst)), "DEBUG")
 
 	sourceFile, err := os.Open(src)
@@ -341,7 +639,8 @@ func copyFileWithProgress(src, dst string) error {
 	}
 	defer destinationFile.Close()
 
-	_, err = io.Copy(destinationFile, sourceFile)
+	reader := &countingReader{r: sourceFile, done: doneBytes, total: totalBytes, path: src, progress: progress}
+	_, err = io.Copy(destinationFile, reader)
 	if err != nil {
 		return fmt.Errorf("Failed to copy file content: %v", err)
 	}
@@ -355,8 +654,9 @@ func copyFileWithProgress(src, dst string) error {
 	return nil
 }
 
-// copyDirWithProgress multi-goroutine directory copy
-func copyDirWithProgress(src, dst string, maxThreads int) error {
+// copyDirWithProgress multi-goroutine directory copy; accepts ctx so pending copy tasks
+// can be cancelled early on SIGINT/SIGTERM, and reports aggregate byte progress via progress
+func copyDirWithProgress(ctx context.Context, src, dst string, maxThreads int, progress Progress) error {
 	if _, err := os.Stat(dst); os.IsNotExist(err) {
 		if err := os.MkdirAll(dst, 0755); err != nil {
 			return fmt.Errorf("Failed to create destination directory: %v", err)
@@ -367,6 +667,7 @@ func copyDirWithProgress(src, dst string, maxThreads int) error {
 	// Collect all files
 	var files []string
 	var dirs []string
+	var totalBytes int64
 
 	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -377,6 +678,7 @@ func copyDirWithProgress(src, dst string, maxThreads int) error {
 			dirs = append(dirs, path)
 		} else {
 			files = append(files, path)
+			totalBytes += info.Size()
 		}
 		return nil
 	})
@@ -409,6 +711,7 @@ func copyDirWithProgress(src, dst string, maxThreads int) error {
 	tasks := make(chan copyTask, len(files))
 	errors := make(chan error, len(files))
 	var wg sync.WaitGroup
+	var doneBytes int64
 
 	// Start workers
 	for i := 0; i < maxThreads; i++ {
@@ -416,7 +719,7 @@ func copyDirWithProgress(src, dst string, maxThreads int) error {
 		go func() {
 			defer wg.Done()
 			for task := range tasks {
-				if err := copyFileWithProgress(task.src, task.dst); err != nil {
+				if err := copyFileWithProgress(ctx, task.src, task.dst, &doneBytes, totalBytes, progress); err != nil {
 					errors <- err
 				}
 			}
@@ -446,181 +749,6 @@ func copyDirWithProgress(src, dst string, maxThreads int) error {
 	}
 }
 
-// extractWith7z extract using 7z
-func extractWith7z(archivePath, destDir string) error {
-	pluginPrint(fmt.Sprintf("Extracting with 7z: %s", archivePath), "INFO")
-	pluginPrint(fmt.Sprintf("Extracting to: %s --> %s", archivePath, destDir), "INFO")
-
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command(pluginConfig.Compression.Exe7zPath, "x", archivePath, "-o"+destDir, "-y")
-	} else {
-		cmd = exec.Command("7z", "x", archivePath, "-o"+destDir, "-y")
-	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("7z extraction failed: %v\nOutput: %s", err, string(output))
-	}
-
-	pluginPrint("7z extraction completed", "SUCCESS")
-	return nil
-}
-
-// extractWithTarGz extract using tar
-func extractWithTarGz(archivePath, destDir string) error {
-	pluginPrint(fmt.Sprintf("Extracting with tar: %s", archivePath), "INFO")
-	pluginPrint(fmt.Sprintf("Extracting to: %s --> %s", archivePath, destDir), "INFO")
-
-	file, err := os.Open(archivePath)
-	if err != nil {
-		return fmt.Errorf("Failed to open archive file: %v", err)
-	}
-	defer file.Close()
-
-	gzReader, err := gzip.NewReader(file)
-	if err != nil {
-		return fmt.Errorf("Failed to create gzip reader: %v", err)
-	}
-	defer gzReader.Close()
-
-	tarReader := tar.NewReader(gzReader)
-
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("Failed to read tar header: %v", err)
-		}
-
-		targetPath := filepath.Join(destDir, header.Name)
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(targetPath, 0755); err != nil {
-				return fmt.Errorf("Failed to create directory: %v", err)
-			}
-		case tar.TypeReg:
-			// Create directory
-			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-				return fmt.Errorf("Failed to create file directory: %v", err)
-			}
-
-			// Create file
-			outFile, err := os.Create(targetPath)
-			if err != nil {
-				return fmt.Errorf("Failed to create file: %v", err)
-			}
-
-			if _, err := io.Copy(outFile, tarReader); err != nil {
-				outFile.Close()
-				return fmt.Errorf("Failed to write file: %v", err)
-			}
-			outFile.Close()
-
-			// Set file permissions
-			if err := os.Chmod(targetPath, os.FileMode(header.Mode)); err != nil {
-				return fmt.Errorf("Failed to set file permissions: %v", err)
-			}
-		}
-	}
-
-	pluginPrint("tar extraction completed", "SUCCESS")
-	return nil
-}
-
-// compressWith7z compress using 7z
-func compressWith7z(srcDir, destFile string) error {
-	pluginPrint(fmt.Sprintf("Compressing with 7z: %s", srcDir), "INFO")
-	pluginPrint(fmt.Sprintf("Compressing to: %s --> %s", srcDir, destFile), "INFO")
-
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command(pluginConfig.Compression.Exe7zPath, "a", destFile, srcDir+string(filepath.Separator)+"*", "-y")
-	} else {
-		cmd = exec.Command("7z", "a", destFile, srcDir+string(filepath.Separator)+"*", "-y")
-	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("7z compression failed: %v\nOutput: %s", err, string(output))
-	}
-
-	pluginPrint("7z compression completed", "SUCCESS")
-	pluginPrint(fmt.Sprintf("Backup file saved: %s", destFile), "SUCCESS")
-	return nil
-}
-
-// compressWithTarGz compress using tar
-func compressWithTarGz(srcDir, destFile string) error {
-	pluginPrint(fmt.Sprintf("Compressing with tar: %s", srcDir), "INFO")
-	pluginPrint(fmt.Sprintf("Compressing to: %s --> %s", srcDir, destFile), "INFO")
-
-	file, err := os.Create(destFile)
-	if err != nil {
-		return fmt.Errorf("Failed to create archive file: %v", err)
-	}
-	defer file.Close()
-
-	gzWriter := gzip.NewWriter(file)
-	defer gzWriter.Close()
-
-	tarWriter := tar.NewWriter(gzWriter)
-	defer tarWriter.Close()
-
-	baseDir := filepath.Dir(srcDir)
-	dirName := filepath.Base(srcDir)
-
-	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Create tar header
-		header, err := tar.FileInfoHeader(info, "")
-		if err != nil {
-			return err
-		}
-
-		// Adjust path
-		relPath, err := filepath.Rel(baseDir, path)
-		if err != nil {
-			return err
-		}
-		header.Name = filepath.Join(dirName, relPath)
-
-		// Write header
-		if err := tarWriter.WriteHeader(header); err != nil {
-			return err
-		}
-
-		// If it's a file, write content
-		if !info.IsDir() {
-			file, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-
-			if _, err := io.Copy(tarWriter, file); err != nil {
-				return err
-			}
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("Error occurred during compression: %v", err)
-	}
-
-	pluginPrint("tar compression completed", "SUCCESS")
-	pluginPrint(fmt.Sprintf("Backup file saved: %s", destFile), "SUCCESS")
-	return nil
-}
-
 // isProcessRunning check if process is running
 func isProcessRunning(processName string) bool {
 	processes, err := ps.Processes()
@@ -637,17 +765,6 @@ func isProcessRunning(processName string) bool {
 	return false
 }
 
-// waitForProcessExit wait for process to exit
-func waitForProcessExit(processName string) {
-	pluginPrint(fmt.Sprintf("Detected %s process is running, waiting for server to shutdown", processName), "WARNING")
-
-	for isProcessRunning(processName) {
-		time.Sleep(1 * time.Second)
-	}
-
-	pluginPrint("Server has been shut down", "SUCCESS")
-}
-
 // removeDir remove directory
 func removeDir(dir string) error {
 	pluginPrint(fmt.Sprintf("Removing directory: %s", dir), "INFO")
@@ -680,10 +797,17 @@ func removeDir(dir string) error {
 	return nil
 }
 
-// backupCurrentWorld backup current world
-func backupCurrentWorld() error {
+// backupCurrentWorld backup current world; accepts ctx so it can bail out early
+// on an incoming SIGINT/SIGTERM instead of finishing an unwanted copy/compress
+func backupCurrentWorld(ctx context.Context) error {
 	pluginPrint("Configured to automatically backup current world before restore", "INFO")
 
+	// Take one config snapshot up front and read only from it for the rest of this function —
+	// in daemon mode this function is invoked by the cron-scheduled goroutine, which can run
+	// concurrently with a reload triggered by SIGHUP/config file changes, so re-reading the
+	// global repeatedly would risk observing it mid-swap
+	cfg := currentConfig()
+
 	// Get current time as part of backup name
 	currentTime := time.Now().Format("20060102_150405")
 	backupName := fmt.Sprintf("before_restore_%s", currentTime)
@@ -711,20 +835,20 @@ func backupCurrentWorld() error {
 
 	pluginPrint(fmt.Sprintf("Backing up current world: %s", worldsDir), "INFO")
 
-	if err := copyDirWithProgress(worldsDir, tempWorldBackupDir, globalConfig.MaxWorkers); err != nil {
+	if err := copyDirWithProgress(ctx, worldsDir, tempWorldBackupDir, globalConfig.MaxWorkers, newThrottledProgress()); err != nil {
 		return fmt.Errorf("Failed to backup world directory: %v", err)
 	}
 
 	// Choose compression method based on configuration
 	var oldBackupFilePath string
-	compressionMethod := pluginConfig.Compression.Method
+	compressionMethod := cfg.Compression.Method
 	if compressionMethod == "" {
 		compressionMethod = "zip" // Default to zip
 	}
 
 	// Get file extension
 	var fileExtension string
-	if format, ok := pluginConfig.Compression.Formats[compressionMethod]; ok {
+	if format, ok := cfg.Compression.Formats[compressionMethod]; ok {
 		fileExtension = format.Extension
 	} else {
 		fileExtension = ".zip" // Default extension
@@ -732,27 +856,156 @@ func backupCurrentWorld() error {
 
 	oldBackupFilePath = filepath.Join(backupDir, backupName+fileExtension)
 
-	// Choose compression function based on compression method
-	switch compressionMethod {
-	case "7z", "zip":
-		if err := compressWith7z(tempWorldBackupDir, oldBackupFilePath); err != nil {
-			return err
-		}
-	case "tar":
-		if err := compressWithTarGz(tempWorldBackupDir, oldBackupFilePath); err != nil {
-			return err
+	snapshot, err := snapshotWorldDir(tempWorldBackupDir)
+	if err != nil {
+		return fmt.Errorf("failed to fingerprint world files: %v", err)
+	}
+
+	backupMode := cfg.Backup.Mode
+	if backupMode == "" {
+		backupMode = backupModeFull
+	}
+
+	var parentBackupID string
+	if backupMode == backupModeIncremental {
+		parentPath, parentManifest, err := latestBackupForWorld(backupDir, restoreInfo.WorldName)
+		if err != nil {
+			pluginPrint(fmt.Sprintf("Failed to find parent backup, falling back to full backup: %v", err), "WARNING")
+			backupMode = backupModeFull
+		} else if parentManifest == nil {
+			pluginPrint("No usable parent backup found, performing a full backup this time", "INFO")
+			backupMode = backupModeFull
+		} else if retentionCount := cfg.Backup.RetentionCount; retentionCount > 0 {
+			if depth, err := incrementalChainDepth(backupDir, parentManifest); err != nil {
+				pluginPrint(fmt.Sprintf("Failed to compute incremental chain depth, falling back to full backup: %v", err), "WARNING")
+				backupMode = backupModeFull
+			} else if depth+1 >= retentionCount {
+				pluginPrint(fmt.Sprintf("Incremental chain depth (%d) has reached retention_count (%d), forcing a full backup this time", depth+1, retentionCount), "INFO")
+				backupMode = backupModeFull
+			}
 		}
-	default:
-		// Default to 7z compression
-		if err := compressWith7z(tempWorldBackupDir, oldBackupFilePath); err != nil {
-			return err
+
+		if backupMode == backupModeIncremental {
+			changes := computeChangeset(snapshot, parentManifest)
+			patchPath := filepath.Join(backupDir, backupName) + patchExtension
+			whiteouts, err := writePatchLayer(ctx, tempWorldBackupDir, patchPath, changes)
+			if err != nil {
+				return fmt.Errorf("failed to write incremental patch: %v", err)
+			}
+
+			parentBackupID = filepath.Base(parentPath)
+			pluginPrint(fmt.Sprintf("Incremental backup completed, %d changes, parent backup: %s", len(changes), parentBackupID), "SUCCESS")
+
+			archiveHash, err := hashFile(patchPath)
+			if err != nil {
+				return fmt.Errorf("failed to hash patch layer: %v", err)
+			}
+
+			patchInfo, err := os.Stat(patchPath)
+			if err != nil {
+				return fmt.Errorf("failed to read patch layer file info: %v", err)
+			}
+			if err := writeArchiveMetadata(patchPath, &ArchiveMetadata{
+				OriginalSize:   totalFileSize(snapshot),
+				CompressedSize: patchInfo.Size(),
+				Method:         compressionMethod,
+				CreatedAt:      time.Now(),
+				WorldName:      restoreInfo.WorldName,
+				SHA256:         archiveHash,
+			}); err != nil {
+				return err
+			}
+
+			manifest := &BackupManifest{
+				BackupID:       backupName,
+				ParentBackupID: parentBackupID,
+				WorldName:      restoreInfo.WorldName,
+				Mode:           backupModeIncremental,
+				CreatedAt:      time.Now(),
+				Files:          snapshot,
+				ArchiveHash:    archiveHash,
+			}
+			if err := writeManifest(filepath.Join(backupDir, backupName), manifest, whiteouts); err != nil {
+				return err
+			}
+			if err := uploadBackupArtifacts(ctx, patchPath, patchPath+metadataSuffix,
+				filepath.Join(backupDir, backupName)+manifestSuffix, filepath.Join(backupDir, backupName)+".whiteouts.json"); err != nil {
+				pluginPrint(fmt.Sprintf("failed to push incremental backup to remote storage: %v", err), "WARNING")
+			}
+			if err := pruneOldBackups(backupDir, restoreInfo.WorldName, cfg.Backup.Retention); err != nil {
+				pluginPrint(fmt.Sprintf("failed to apply retention policy: %v", err), "WARNING")
+			}
+			return nil
 		}
 	}
 
+	archiver := newArchiver(externalExe7zPath(cfg.Compression))
+	if err := archiver.Compress(ctx, tempWorldBackupDir, oldBackupFilePath, newThrottledProgress()); err != nil {
+		return err
+	}
+
+	archiveHash, err := hashFile(oldBackupFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to hash backup archive: %v", err)
+	}
+
+	archiveInfo, err := os.Stat(oldBackupFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup archive file info: %v", err)
+	}
+	if err := writeArchiveMetadata(oldBackupFilePath, &ArchiveMetadata{
+		OriginalSize:   totalFileSize(snapshot),
+		CompressedSize: archiveInfo.Size(),
+		Method:         compressionMethod,
+		CreatedAt:      time.Now(),
+		WorldName:      restoreInfo.WorldName,
+		SHA256:         archiveHash,
+	}); err != nil {
+		return err
+	}
+
+	manifest := &BackupManifest{
+		BackupID:    backupName,
+		WorldName:   restoreInfo.WorldName,
+		Mode:        backupModeFull,
+		CreatedAt:   time.Now(),
+		Files:       snapshot,
+		ArchiveHash: archiveHash,
+	}
+	if err := writeManifest(filepath.Join(backupDir, backupName), manifest, nil); err != nil {
+		return err
+	}
+
+	if err := uploadBackupArtifacts(ctx, oldBackupFilePath, oldBackupFilePath+metadataSuffix,
+		filepath.Join(backupDir, backupName)+manifestSuffix); err != nil {
+		pluginPrint(fmt.Sprintf("failed to push backup to remote storage: %v", err), "WARNING")
+	}
+
+	if err := pruneOldBackups(backupDir, restoreInfo.WorldName, cfg.Backup.Retention); err != nil {
+		pluginPrint(fmt.Sprintf("failed to apply retention policy: %v", err), "WARNING")
+	}
+
 	pluginPrint("Pre-restore backup completed", "SUCCESS")
 	return nil
 }
 
+// externalExe7zPath only returns a non-empty path when the user explicitly enabled
+// use_external_7z; otherwise compression/extraction always go through the built-in
+// pure-Go archiver implementation. compression is passed in by the caller instead of
+// being read from the global pluginConfig here, so backupCurrentWorld can pass the
+// snapshot it took once at entry and not race a concurrent config reload mid-backup
+func externalExe7zPath(compression CompressionConfig) string {
+	if !compression.UseExternal7z {
+		return ""
+	}
+
+	if compression.Exe7zPath != "" {
+		return compression.Exe7zPath
+	}
+
+	return "7z"
+}
+
 // restartServer restart server
 func restartServer() {
 	restartConfig := pluginConfig.Restore.Config.RestartServer
@@ -772,7 +1025,11 @@ func restartServer() {
 
 	startScriptPath := restartConfig.StartScriptPath
 	if startScriptPath == "" {
-		startScriptPath = "./start.bat"
+		if runtime.GOOS == "windows" {
+			startScriptPath = "./start.bat"
+		} else {
+			startScriptPath = "./start.sh"
+		}
 	}
 
 	pluginPrint(fmt.Sprintf("Start script path: %s", startScriptPath), "INFO")
@@ -788,53 +1045,77 @@ func restartServer() {
 	pluginPrint(fmt.Sprintf("Server directory: %s", restoreInfo.ServerDir), "INFO")
 	pluginPrint(fmt.Sprintf("Full path of start script: %s", startScriptFullPath), "INFO")
 
-	// Execute start script
-	pluginPrint("Starting server...", "INFO")
-
 	// Check if script file exists
 	if _, err := os.Stat(startScriptFullPath); os.IsNotExist(err) {
 		pluginPrint(fmt.Sprintf("Start script does not exist: %s", startScriptFullPath), "ERROR")
 		return
 	}
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		// On Windows, use start command to open a new window to execute batch file
-		cmd_path := os.Getenv("PATH")
-		pluginPrint(cmd_path, "INFO")
-		cmd = exec.Command("C:\\Windows\\System32\\cmd.exe", "/c", "start", "/I", startScriptFullPath)
-	} else {
-		// On Linux/Mac, execute script file directly
-		// cmd = exec.Command(startScriptFullPath)
-		// Not yet supported on Linux/Mac
-		pluginPrint("Linux/Mac not yet supported", "ERROR")
-		pluginPrint("Please start server manually", "INFO")
+	// Starting server: the actual mechanism lives in the platform-specific
+	// startServerProcessPlatform (Linux/macOS sets up a stdin named pipe to
+	// support stop_command afterwards)
+	pluginPrint("Starting server...", "INFO")
+
+	pid, err := startServerProcessPlatform(restoreInfo.ServerDir, startScriptFullPath, logFile)
+	if err != nil {
+		pluginPrint(fmt.Sprintf("Failed to start server: %v", err), "ERROR")
 		return
 	}
 
-	// Set working directory
-	cmd.Dir = restoreInfo.ServerDir
-
-	// Print command info for debugging
-	pluginPrint(fmt.Sprintf("Executing command: %s", cmd.String()), "INFO")
-	pluginPrint(fmt.Sprintf("Working directory: %s", cmd.Dir), "INFO")
-
-	// Execute command and wait for completion
-	if err := cmd.Run(); err != nil {
-		pluginPrint(fmt.Sprintf("Failed to start server: %v", err), "ERROR")
-	} else {
-		pluginPrint("Server start command has been executed", "SUCCESS")
+	if err := writePIDFile(pidFilePath(restoreInfo.ServerDir), pid); err != nil {
+		pluginPrint(fmt.Sprintf("Failed to write pid file: %v", err), "WARNING")
 	}
+
+	pluginPrint(fmt.Sprintf("Server start command has been executed, PID %d", pid), "SUCCESS")
 }
 
 // main main function
 func main() {
 	// Parse command line arguments
-	backupFile := flag.String("backup", "", "Backup file path")
+	backupFile := flag.String("backup", "", "Backup file path; accepts a local path or a remote URI of the form s3://bucket/key, webdav://path, sftp://user@host/path, or https://host/path")
 	serverDir := flag.String("server", "", "Server directory")
 	worldName := flag.String("world", "", "World name")
+	onlyGlob := flag.String("only", "", "Only restore relative paths matching this glob (e.g. db/* or a specific dimension directory); omit to restore everything. Only saves work on chunked zstd archives")
+	verifyOnly := flag.Bool("verify", false, "Only check the -backup archive's metadata sidecar (size + sha256) without extracting or restoring")
+	daemonMode := flag.Bool("daemon", false, "Run in long-lived daemon mode: watch SIGHUP and config file changes for hot reload, and with -schedule periodically run world backups. Never restores anything")
+	schedule := flag.String("schedule", "", "Daemon mode only: a cron expression (e.g. 0 3 * * *) for periodic world backups; leave empty for a daemon that only hot-reloads config")
 	flag.Parse()
 
+	// Daemon mode never restores anything, so it only needs -server to locate the config file and world directory
+	if *daemonMode {
+		if *serverDir == "" {
+			fmt.Println("Usage: easybackuper -daemon -server <server_directory> [-schedule <cron_expression>]")
+			fmt.Println("Missing required parameters")
+			os.Exit(1)
+		}
+		if err := setupLogging(*serverDir); err != nil {
+			fmt.Printf("Failed to setup logging: %v\n", err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+
+		restoreInfo = RestoreInfo{ServerDir: *serverDir}
+		if err := os.Chdir(*serverDir); err != nil {
+			pluginPrint(fmt.Sprintf("Failed to change working directory: %v", err), "ERROR")
+			os.Exit(1)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigCh
+			pluginPrint("Received interrupt signal, daemon is shutting down...", "WARNING")
+			cancel()
+		}()
+
+		if err := runDaemon(ctx, *serverDir, *schedule); err != nil {
+			pluginPrint(fmt.Sprintf("Daemon mode exited with an error: %v", err), "ERROR")
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Check required parameters
 	if *backupFile == "" || *serverDir == "" || *worldName == "" {
 		fmt.Println("Usage: easybackuper -backup <backup_file> -server <server_directory> -world <world_name>")
@@ -873,6 +1154,49 @@ func main() {
 	pluginPrint(fmt.Sprintf("Working directory: %s", restoreInfo.ServerDir), "INFO")
 	pluginPrint(strings.Repeat("=", 60), "INFO")
 
+	// Retry any restore status notifications left over in the local queue from the
+	// previous process exit, before doing anything else this run
+	flushNotificationQueue(restoreInfo.ServerDir, pluginConfig.Restore.Notifications)
+
+	// -verify only checks archive integrity; it never extracts or touches the server
+	// process or the world directory
+	if *verifyOnly {
+		pluginPrint(fmt.Sprintf("Checking metadata sidecar for archive %s", restoreInfo.BackupFile), "INFO")
+		if err := verifyArchiveMetadataFile(restoreInfo.BackupFile); err != nil {
+			pluginPrint(fmt.Sprintf("Archive integrity check failed: %v", err), "ERROR")
+			os.Exit(1)
+		}
+		pluginPrint("Archive integrity check passed", "SUCCESS")
+		return
+	}
+
+	// tempDir is only assigned a non-empty value once it's created, so cleanup only
+	// actually removes it after that point. backupCurrentWorld's own temporary directory
+	// is cleaned up by its own defer when it returns normally; here we only manage the extraction tempDir.
+	// Declared early so the signal-handling goroutine below can capture the later assignment
+	var tempDir string
+	cleanup := func() {
+		if tempDir != "" {
+			os.RemoveAll(tempDir)
+		}
+	}
+
+	// Install a SIGINT/SIGTERM handler that cancels ctx and cleans up temporary directories,
+	// so a half-finished restore doesn't leave orphan directories under
+	// temp_easybackuper/temp_easybackuper_backup
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		pluginPrint("Received interrupt signal, cancelling current operation and cleaning up temporary directories...", "WARNING")
+		cancel()
+		cleanup()
+		// os.Exit skips the defers in backupCurrentWorld, so its temporary directory is removed here too
+		os.RemoveAll(filepath.Join(restoreInfo.ServerDir, "temp_easybackuper_backup"))
+		os.Exit(130)
+	}()
+
 	// Change working directory
 	if err := os.Chdir(restoreInfo.ServerDir); err != nil {
 		pluginPrint(fmt.Sprintf("Failed to change working directory: %v", err), "ERROR")
@@ -888,8 +1212,13 @@ func main() {
 		processName = "bedrock_server"
 	}
 
-	if isProcessRunning(processName) {
-		waitForProcessExit(processName)
+	if isServerRunning(restoreInfo.ServerDir, processName) {
+		restartConfig := pluginConfig.Restore.Config.RestartServer
+		pluginPrint("Trying to request graceful server shutdown", "INFO")
+		if err := sendStopCommand(restoreInfo.ServerDir, restartConfig); err != nil {
+			pluginPrint(fmt.Sprintf("Failed to send shutdown request, falling back to waiting for timeout then forcing termination: %v", err), "WARNING")
+		}
+		waitForProcessExit(restoreInfo.ServerDir, processName, restartConfig.StopTimeoutS)
 	} else {
 		pluginPrint(fmt.Sprintf("No %s process detected, continuing with restore operation", processName), "INFO")
 	}
@@ -898,7 +1227,7 @@ func main() {
 
 	// Check if we need to backup current world before restore
 	if pluginConfig.Restore.Config.BackupOldWorldBeforeRestore {
-		if err := backupCurrentWorld(); err != nil {
+		if err := backupCurrentWorld(ctx); err != nil {
 			pluginPrint(fmt.Sprintf("Pre-restore backup failed: %v", err), "ERROR")
 			// Continue execution, do not terminate
 		}
@@ -909,11 +1238,15 @@ func main() {
 	pluginPrint(strings.Repeat("=", 60), "INFO")
 
 	// Restore backup
+	restoreStartTime := time.Now()
 	pluginPrint("Starting backup restore", "INFO")
+	if *onlyGlob != "" {
+		pluginPrint(fmt.Sprintf("-only subset restore enabled, match pattern: %s", *onlyGlob), "INFO")
+	}
 	worldsDir := filepath.Join(restoreInfo.ServerDir, "worlds")
 
 	// Create temporary directory for extraction
-	tempDir := filepath.Join(restoreInfo.ServerDir, "temp_easybackuper")
+	tempDir = filepath.Join(restoreInfo.ServerDir, "temp_easybackuper")
 	if _, err := os.Stat(tempDir); err == nil {
 		os.RemoveAll(tempDir)
 	}
@@ -922,85 +1255,246 @@ func main() {
 		pluginPrint(fmt.Sprintf("Failed to create temporary directory: %v", err), "ERROR")
 		os.Exit(1)
 	}
-	defer os.RemoveAll(tempDir)
 
 	pluginPrint(fmt.Sprintf("Created temporary directory: %s", tempDir), "INFO")
 
 	tempWorldDir := filepath.Join(tempDir, restoreInfo.WorldName)
 	backupFilePath := restoreInfo.BackupFile
 
-	var err error
-	// Choose extraction method based on file extension
-	if strings.HasSuffix(strings.ToLower(backupFilePath), ".7z") {
-		pluginPrint("Detected .7z format backup file, using 7z to extract", "INFO")
-		err = extractWith7z(backupFilePath, tempWorldDir)
-	} else if strings.HasSuffix(strings.ToLower(backupFilePath), ".zip") {
-		pluginPrint("Detected .zip format backup file, using 7z to extract", "INFO")
-		err = extractWith7z(backupFilePath, tempWorldDir)
-	} else if strings.HasSuffix(strings.ToLower(backupFilePath), ".tar.gz") || strings.HasSuffix(strings.ToLower(backupFilePath), ".tgz") {
-		pluginPrint("Detected .tar.gz format backup file, using tar to extract", "INFO")
-		err = extractWithTarGz(backupFilePath, tempWorldDir)
-		// If tar extraction fails, try using 7z
-		if err != nil {
-			pluginPrint("tar extraction failed, trying to use 7z to extract", "WARNING")
-			err = extractWith7z(backupFilePath, tempWorldDir)
+	// Extraction: no more branching on file extension to pick 7z/tar, the archiver
+	// now sniffs the format from the file header uniformly; if this backup is one
+	// layer of an incremental chain, walk the parent chain first and apply patches in order
+	backupIDPath := backupIDPathFromFile(backupFilePath)
+
+	manifest, err := readManifest(backupIDPath)
+	if err != nil {
+		pluginPrint(fmt.Sprintf("Failed to read backup manifest: %v", err), "ERROR")
+		cleanup()
+		os.Exit(1)
+	}
+
+	verifyLevel := pluginConfig.Backup.Verify
+	if verifyLevel == "" {
+		verifyLevel = verifyNone
+	}
+
+	if verifyLevel != verifyNone {
+		if manifest == nil {
+			pluginPrint("This backup has no manifest (likely produced by an older version), skipping integrity check", "WARNING")
+		} else {
+			pluginPrint(fmt.Sprintf("Starting backup integrity check (level: %s)", verifyLevel), "INFO")
+			if err := verifyArchiveChain(ctx, backupFilePath, backupIDPath, manifest); err != nil {
+				pluginPrint(fmt.Sprintf("Backup integrity check failed, restore aborted: %v", err), "ERROR")
+				cleanup()
+				os.Exit(1)
+			}
+			pluginPrint("Backup archive integrity check passed", "SUCCESS")
+		}
+	}
+
+	if manifest != nil && manifest.Mode == backupModeIncremental {
+		pluginPrint(fmt.Sprintf("Detected incremental backup %s, walking parent backup chain", manifest.BackupID), "INFO")
+		if err := applyPatchChain(ctx, backupIDPath, manifest, tempWorldDir, *onlyGlob); err != nil {
+			pluginPrint(fmt.Sprintf("Failed to apply incremental backup chain: %v", err), "ERROR")
+			cleanup()
+			os.Exit(1)
 		}
 	} else {
-		// Default to settings in configuration
-		compressionMethod := pluginConfig.Compression.Method
-		if compressionMethod == "" {
-			compressionMethod = "zip" // Default to zip
+		archiver := newArchiver(externalExe7zPath(currentConfig().Compression))
+		if err := archiver.Extract(ctx, backupFilePath, tempWorldDir, *onlyGlob, newThrottledProgress()); err != nil {
+			pluginPrint(fmt.Sprintf("Extraction failed: %v", err), "ERROR")
+			cleanup()
+			os.Exit(1)
 		}
+	}
 
-		switch compressionMethod {
-		case "7z", "zip":
-			pluginPrint("Using 7z from configuration to extract", "INFO")
-			err = extractWith7z(backupFilePath, tempWorldDir)
-		case "tar":
-			pluginPrint("Using tar from configuration to extract", "INFO")
-			err = extractWithTarGz(backupFilePath, tempWorldDir)
-		default:
-			// Default to 7z extraction
-			pluginPrint("Using default 7z to extract", "INFO")
-			err = extractWith7z(backupFilePath, tempWorldDir)
+	if verifyLevel == verifyPerFile && manifest != nil {
+		if *onlyGlob != "" {
+			pluginPrint("-only subset restore is enabled, skipping per-file integrity check (the manifest records the full file tree)", "WARNING")
+		} else {
+			pluginPrint("Starting per-file hash check of extracted files", "INFO")
+			if err := verifyExtractedFiles(tempWorldDir, manifest.Files); err != nil {
+				pluginPrint(fmt.Sprintf("Extracted file integrity check failed, restore aborted: %v", err), "ERROR")
+				cleanup()
+				os.Exit(1)
+			}
+			pluginPrint("Extracted file integrity check passed", "SUCCESS")
 		}
 	}
 
-	if err != nil {
-		pluginPrint(fmt.Sprintf("Extraction failed: %v", err), "ERROR")
-		os.Exit(1)
+	// An -only subset restore only lands part of the file tree by design and doesn't have
+	// the structure of a complete world, so skip this check in that case; otherwise, before
+	// overwriting the current world directory, confirm the extraction result looks like a
+	// complete world save rather than a partial tree left by a truncated/corrupt archive
+	if *onlyGlob == "" {
+		pluginPrint("Checking extracted world directory structure", "INFO")
+		if err := verifyRestoredWorld(tempWorldDir); err != nil {
+			pluginPrint(fmt.Sprintf("Extracted world directory failed structure check, restore aborted, current world directory untouched: %v", err), "ERROR")
+			cleanup()
+			os.Exit(1)
+		}
+		pluginPrint("World directory structure check passed", "SUCCESS")
 	}
 
 	pluginPrint(strings.Repeat("=", 60), "INFO")
 
-	// Remove existing world directory
 	currentWorldDir := filepath.Join(worldsDir, restoreInfo.WorldName)
+	worldWasPresent := false
 	if _, err := os.Stat(currentWorldDir); err == nil {
-		if err := removeDir(currentWorldDir); err != nil {
-			pluginPrint(fmt.Sprintf("Failed to remove old world directory: %v", err), "ERROR")
-			// Continue execution
+		worldWasPresent = true
+	}
+
+	var bytesRestored int64
+	var rollbackDir string
+
+	if *onlyGlob != "" {
+		// -only only ever extracted the matched subset, not a complete world, so it can't
+		// replace the current world directory the same way the full-directory swap below
+		// does — that would wipe out every file that wasn't matched (e.g. dimension
+		// directories not selected by -only). Instead, merge the extracted subset file by
+		// file on top of the current world directory: only the matched files get
+		// overwritten, everything else is left untouched.
+		// The tradeoff is this in-place merge has no whole-directory rollback — there's no
+		// saved copy of the files it overwrote, so if the health check below fails, all we
+		// can do is tell the user to check manually instead of swapping the old directory
+		// back in one shot
+		pluginPrint("-only subset restore enabled, merging matched files in place instead of swapping the whole directory", "INFO")
+		pluginPrint(fmt.Sprintf("Merge target: %s ==> %s", tempWorldDir, currentWorldDir), "INFO")
+		pluginPrint(fmt.Sprintf("Using %d goroutines for file copying", globalConfig.MaxWorkers), "INFO")
+
+		if err := copyDirWithProgress(ctx, tempWorldDir, currentWorldDir, globalConfig.MaxWorkers, newThrottledProgress()); err != nil {
+			pluginPrint(fmt.Sprintf("Failed to merge subset files: %v", err), "ERROR")
+			sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+				World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusFailed,
+				DurationMs: time.Since(restoreStartTime).Milliseconds(), Error: err.Error(),
+			})
+			cleanup()
+			os.Exit(1)
+		}
+
+		bytesRestored, _ = dirSize(tempWorldDir)
+		pluginPrint("Subset file merge completed", "SUCCESS")
+	} else {
+		// First copy the extracted world into a sibling staging directory under worlds/,
+		// without touching the current world directory at all: whatever fails, the current
+		// world is either left completely untouched or has already been atomically renamed away
+		swapTimestamp := time.Now().Format("20060102_150405")
+		stagingDir := stagingWorldDir(worldsDir, restoreInfo.WorldName, swapTimestamp)
+		rollbackDir = rollbackWorldDirFor(worldsDir, restoreInfo.WorldName, swapTimestamp)
+
+		pluginPrint("Starting file copy...", "INFO")
+		pluginPrint(fmt.Sprintf("Copy target: %s ==> %s", tempWorldDir, stagingDir), "INFO")
+		pluginPrint(fmt.Sprintf("Using %d goroutines for file copying", globalConfig.MaxWorkers), "INFO")
+
+		if err := copyDirWithProgress(ctx, tempWorldDir, stagingDir, globalConfig.MaxWorkers, newThrottledProgress()); err != nil {
+			pluginPrint(fmt.Sprintf("Failed to copy to staging directory: %v", err), "ERROR")
+			removeDir(stagingDir)
+			sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+				World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusFailed,
+				DurationMs: time.Since(restoreStartTime).Milliseconds(), Error: err.Error(),
+			})
+			cleanup()
+			os.Exit(1)
+		}
+
+		bytesRestored, _ = dirSize(stagingDir)
+
+		pluginPrint("File copy completed", "SUCCESS")
+		pluginPrint(strings.Repeat("=", 60), "INFO")
+
+		// Move the current world directory to the rollback directory, then swap the staging
+		// directory into the current world directory's place; worldWasPresent records whether
+		// the current world directory existed in the first place, deciding whether a failure
+		// needs to swap anything back
+		if worldWasPresent {
+			if err := os.Rename(currentWorldDir, rollbackDir); err != nil {
+				pluginPrint(fmt.Sprintf("Failed to move current world directory to rollback directory, aborting restore: %v", err), "ERROR")
+				removeDir(stagingDir)
+				sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+					World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusFailed,
+					DurationMs: time.Since(restoreStartTime).Milliseconds(), BytesRestored: bytesRestored, Error: err.Error(),
+				})
+				cleanup()
+				os.Exit(1)
+			}
+		}
+
+		if err := os.Rename(stagingDir, currentWorldDir); err != nil {
+			pluginPrint(fmt.Sprintf("Failed to swap in the new world directory, swapping the rollback directory back: %v", err), "ERROR")
+			if worldWasPresent {
+				if rbErr := rollbackWorld(rollbackDir, currentWorldDir); rbErr != nil {
+					pluginPrint(fmt.Sprintf("Failed to swap the rollback directory back: %v", rbErr), "ERROR")
+				}
+			}
+			sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+				World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusFailed,
+				DurationMs: time.Since(restoreStartTime).Milliseconds(), BytesRestored: bytesRestored, Error: err.Error(),
+			})
+			cleanup()
+			os.Exit(1)
 		}
 	}
 
+	pluginPrint("Backup restore completed", "SUCCESS")
 	pluginPrint(strings.Repeat("=", 60), "INFO")
 
-	// Copy files from temporary directory to target directory
-	pluginPrint("Starting file copy...", "INFO")
-	pluginPrint(fmt.Sprintf("Copy target: %s ==> %s", tempWorldDir, worldsDir), "INFO")
-	pluginPrint(fmt.Sprintf("Using %d goroutines for file copying", globalConfig.MaxWorkers), "INFO")
+	// The world directory has been swapped successfully; send a "restored" status
+	// notification before restarting the server. The restart outcome (success/failure)
+	// is reported by the second notification after the health check below
+	sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+		World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusRestored,
+		DurationMs: time.Since(restoreStartTime).Milliseconds(), BytesRestored: bytesRestored,
+	})
 
-	if err := copyDirWithProgress(tempWorldDir, worldsDir, globalConfig.MaxWorkers); err != nil {
-		pluginPrint(fmt.Sprintf("File copy failed: %v", err), "ERROR")
+	// Restart the server, then confirm within the health check window that the process
+	// actually came up; if it didn't, treat this restore as failed and automatically swap
+	// the rollback directory back into place rather than leaving a new world the server
+	// can't start against
+	restartServer()
+	restartConfig := pluginConfig.Restore.Config.RestartServer
+	if restartConfig.Status && !waitForServerHealthy(restoreInfo.ServerDir, processName, restartConfig.HealthCheckTimeoutS) {
+		rollbackErr := ""
+		if *onlyGlob != "" {
+			// The in-place merge has no saved copy of the files it overwrote, so there's
+			// nothing to automatically swap back — be honest about it and leave manual
+			// recovery (or restoring from another backup) to the user
+			pluginPrint("Server did not come back up within the health check window; this was an -only subset in-place merge restore, so it cannot be rolled back automatically — please check the world directory manually", "ERROR")
+		} else {
+			pluginPrint("Server did not come back up within the health check window, rolling back world directory", "ERROR")
+			if err := removeDir(currentWorldDir); err != nil {
+				pluginPrint(fmt.Sprintf("Failed to remove the new world directory that failed to start the server: %v", err), "ERROR")
+			}
+			if worldWasPresent {
+				if err := rollbackWorld(rollbackDir, currentWorldDir); err != nil {
+					pluginPrint(fmt.Sprintf("Failed to roll back world directory: %v", err), "ERROR")
+					rollbackErr = err.Error()
+				} else {
+					pluginPrint("Rolled back to the pre-restore world directory, please check the server status manually", "WARNING")
+				}
+			}
+		}
+		sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+			World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusFailed,
+			DurationMs: time.Since(restoreStartTime).Milliseconds(), BytesRestored: bytesRestored,
+			Error: strings.TrimSpace("server did not come back up within the health check window " + rollbackErr),
+		})
+		cancel()
+		cleanup()
 		os.Exit(1)
 	}
 
-	pluginPrint("File copy completed", "SUCCESS")
-	pluginPrint(strings.Repeat("=", 60), "INFO")
+	if worldWasPresent && *onlyGlob == "" {
+		if err := pruneRollbackDirs(worldsDir, restoreInfo.WorldName, pluginConfig.Restore.Config.RollbackKeepCount); err != nil {
+			pluginPrint(fmt.Sprintf("Failed to clean up historical rollback directories: %v", err), "WARNING")
+		}
+	}
 
-	pluginPrint("Backup restore completed", "SUCCESS")
-	pluginPrint(strings.Repeat("=", 60), "INFO")
+	sendRestoreNotification(restoreInfo.ServerDir, pluginConfig.Restore.Notifications, NotificationEvent{
+		World: restoreInfo.WorldName, BackupFile: restoreInfo.BackupFile, Status: notifyStatusSuccess,
+		DurationMs: time.Since(restoreStartTime).Milliseconds(), BytesRestored: bytesRestored,
+	})
 
-	// Restart server
-	restartServer()
+	cancel()
+	cleanup()
 	os.Exit(0)
 }
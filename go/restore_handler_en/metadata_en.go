@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// metadataSuffix is the suffix of the metadata sidecar next to an archive, following the same
+// naming convention as the manifest/whiteouts sidecars
+const metadataSuffix = ".meta.json"
+
+// ArchiveMetadata records metadata about a single layer's archive file (the archive itself for
+// a full backup, the patch layer for an incremental one), so -verify can quickly sanity-check
+// an archive without extracting it
+type ArchiveMetadata struct {
+	OriginalSize   int64     `json:"original_size"`
+	CompressedSize int64     `json:"compressed_size"`
+	Method         string    `json:"method"`
+	CreatedAt      time.Time `json:"created_at"`
+	WorldName      string    `json:"world_name"`
+	// ServerVersion has no reliable source today (this program is invoked as an external
+	// process and has no way to see the endstone server's version), so the field is
+	// reserved for a future caller to populate via config or arguments and is always
+	// empty for now
+	ServerVersion string `json:"server_version,omitempty"`
+	SHA256        string `json:"sha256"`
+}
+
+// totalFileSize sums the size of every file in a snapshot, used for the archive metadata's original_size
+func totalFileSize(snapshot map[string]FileEntry) int64 {
+	var total int64
+	for _, entry := range snapshot {
+		total += entry.Size
+	}
+	return total
+}
+
+// writeArchiveMetadata writes archive metadata to the sidecar next to archivePath
+func writeArchiveMetadata(archivePath string, meta *ArchiveMetadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize archive metadata: %v", err)
+	}
+	if err := os.WriteFile(archivePath+metadataSuffix, data, 0644); err != nil {
+		return fmt.Errorf("failed to write archive metadata: %v", err)
+	}
+	return nil
+}
+
+// readArchiveMetadata reads the metadata sidecar next to an archive; returns nil (not an error)
+// when it is missing, since archives produced by older versions have no such sidecar. archivePath
+// may be a local path or an s3/webdav/sftp remote URI
+func readArchiveMetadata(ctx context.Context, archivePath string) (*ArchiveMetadata, error) {
+	data, err := readSidecarBytes(ctx, archivePath+metadataSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive metadata: %v", err)
+	}
+
+	var meta ArchiveMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse archive metadata: %v", err)
+	}
+	return &meta, nil
+}
+
+// verifyArchiveMetadataFile checks archivePath against its metadata sidecar without extracting:
+// first the (cheap) file size, then a recomputed sha256 compared against the recorded value.
+// archivePath may be a local path or an s3/webdav/sftp remote URI. A missing sidecar returns a
+// readable error rather than silently passing, since -verify is an explicit user request and
+// skipping it would create a false impression that the check passed
+func verifyArchiveMetadataFile(archivePath string) error {
+	// -verify runs before main() constructs a request-level ctx, so there is no caller ctx to
+	// pass in here; readArchiveMetadata/hashArchiveSource both fall back to context.Background()
+	meta, err := readArchiveMetadata(context.Background(), archivePath)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		return fmt.Errorf("archive %s has no metadata sidecar (likely produced by an older version), cannot run -verify", archivePath)
+	}
+
+	if !isRemoteBackupURI(archivePath) {
+		info, err := os.Stat(archivePath)
+		if err != nil {
+			return fmt.Errorf("failed to read archive file info: %v", err)
+		}
+		if info.Size() != meta.CompressedSize {
+			return fmt.Errorf("archive %s size mismatch: expected %d bytes, got %d bytes", archivePath, meta.CompressedSize, info.Size())
+		}
+	}
+
+	hash, err := hashArchiveSource(context.Background(), archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to hash archive: %v", err)
+	}
+	if hash != meta.SHA256 {
+		return fmt.Errorf("sha256 check failed for archive %s, the backup may be corrupted", archivePath)
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// NotificationConfig configures the HTTP callback hook fired when a restore finishes,
+// e.g. a Pterodactyl-style panel, a Discord bot, or a Prometheus Alertmanager receiver,
+// used to mark the server as "restored/active" (similar to wings' SendRestorationStatus)
+type NotificationConfig struct {
+	URL string `json:"url"`
+	// Secret, when non-empty, signs the request body with HMAC-SHA256 and puts the
+	// signature in the X-Signature header so the receiver can verify the request
+	// really came from this plugin
+	Secret string `json:"secret"`
+	// Attempts is the max number of send attempts (including the first), 0 defaults to 3
+	Attempts int `json:"attempts"`
+	// BackoffS is the wait in seconds between consecutive attempts, 0 defaults to 5
+	BackoffS int `json:"backoff_s"`
+	// TimeoutS is the timeout in seconds for a single HTTP request, 0 defaults to 10
+	TimeoutS int `json:"timeout_s"`
+}
+
+// NotificationEvent is the request body sent to an external panel for a restore status callback
+type NotificationEvent struct {
+	World         string `json:"world"`
+	BackupFile    string `json:"backup_file"`
+	Status        string `json:"status"` // restored / success / failed
+	DurationMs    int64  `json:"duration_ms"`
+	BytesRestored int64  `json:"bytes_restored"`
+	Error         string `json:"error,omitempty"`
+}
+
+const (
+	notifyStatusRestored = "restored"
+	notifyStatusSuccess  = "success"
+	notifyStatusFailed   = "failed"
+)
+
+// notificationQueueFileName is where undelivered notification events are persisted,
+// alongside the pid file, one JSON event per line; flushNotificationQueue retries
+// them the next time the process starts
+const notificationQueueFileName = "notification_queue.jsonl"
+
+func notificationQueuePath(serverDir string) string {
+	return filepath.Join(serverDir, "logs", pluginName, notificationQueueFileName)
+}
+
+// sendRestoreNotification tries to deliver a restore status callback to the external panel
+// according to the configured retry policy; an empty URL means the feature is disabled and
+// this is a no-op. Once all retries are exhausted, the event is persisted to the local queue
+// file instead — a failed delivery never aborts the restore/restart flow, and the caller doesn't
+// need to care whether the notification actually got delivered. Sending is synchronous: the
+// caller waits through all attempts, including each request timeout and the backoff between them
+func sendRestoreNotification(serverDir string, cfg NotificationConfig, event NotificationEvent) {
+	if cfg.URL == "" {
+		return
+	}
+
+	if err := deliverNotification(cfg, event); err != nil {
+		pluginPrint(fmt.Sprintf("Failed to deliver restore status notification, queued locally for retry on next startup: %v", err), "WARNING")
+		if qerr := enqueueNotification(serverDir, event); qerr != nil {
+			pluginPrint(fmt.Sprintf("Failed to persist undelivered notification event: %v", qerr), "WARNING")
+		}
+	}
+}
+
+// deliverNotification performs a single HTTP POST for one event, retrying per
+// cfg.Attempts/cfg.BackoffS
+func deliverNotification(cfg NotificationConfig, event NotificationEvent) error {
+	attempts := cfg.Attempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	backoff := cfg.BackoffS
+	if backoff <= 0 {
+		backoff = 5
+	}
+	timeout := cfg.TimeoutS
+	if timeout <= 0 {
+		timeout = 10
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize notification event: %v", err)
+	}
+
+	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(time.Duration(backoff) * time.Second)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, cfg.URL, bytes.NewReader(body))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if cfg.Secret != "" {
+			req.Header.Set("X-Signature", signNotificationBody(cfg.Secret, body))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("received non-2xx response: %d", resp.StatusCode)
+	}
+
+	return lastErr
+}
+
+// signNotificationBody computes an HMAC-SHA256 signature over the request body and returns
+// it hex-encoded; the receiver recomputes it with the same secret to verify the request
+// really came from this plugin
+func signNotificationBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// enqueueNotification appends an undelivered event to the local queue file (one JSON object
+// per line); even if the process exits, these events can be picked up and retried by
+// flushNotificationQueue the next time it starts
+func enqueueNotification(serverDir string, event NotificationEvent) error {
+	path := notificationQueuePath(serverDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create notification queue directory: %v", err)
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize notification event: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open notification queue file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write notification queue file: %v", err)
+	}
+	return nil
+}
+
+// flushNotificationQueue tries to resend notification events left over in the queue from a
+// previous run at process startup. Events that deliver successfully are dropped from the
+// queue, the rest are kept, and the queue file is rewritten as a whole
+func flushNotificationQueue(serverDir string, cfg NotificationConfig) {
+	if cfg.URL == "" {
+		return
+	}
+
+	path := notificationQueuePath(serverDir)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			pluginPrint(fmt.Sprintf("Failed to read notification queue file: %v", err), "WARNING")
+		}
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var remaining []string
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		var event NotificationEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			pluginPrint(fmt.Sprintf("Failed to parse queued notification event, discarding: %v", err), "WARNING")
+			continue
+		}
+		if err := deliverNotification(cfg, event); err != nil {
+			remaining = append(remaining, line)
+			continue
+		}
+		pluginPrint(fmt.Sprintf("Successfully resent queued restore status notification: %s", event.World), "INFO")
+	}
+
+	if len(remaining) == 0 {
+		os.Remove(path)
+		return
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(remaining, "\n")+"\n"), 0644); err != nil {
+		pluginPrint(fmt.Sprintf("Failed to rewrite notification queue file: %v", err), "WARNING")
+	}
+}
+
+// dirSize recursively sums the size of all files under dir, used to report bytes_restored
+// in a notification event; on a walk error it returns the partial total accumulated so far
+// plus the error, and the caller may choose to ignore the error and just use the best-effort value
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
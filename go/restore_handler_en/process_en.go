@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-ps"
+)
+
+const pidFileName = "bedrock_server.pid"
+
+// pidFilePath returns the path of the pid file this plugin uses to record
+// the server process's PID
+func pidFilePath(serverDir string) string {
+	return filepath.Join(serverDir, "logs", pluginName, pidFileName)
+}
+
+// writePIDFile writes the process PID to the pid file, so the next restore
+// can prefer matching by PID instead of the old substring match on the
+// executable name
+func writePIDFile(path string, pid int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create pid file directory: %v", err)
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(pid)), 0644)
+}
+
+// readPIDFile reads the pid file; returns 0 if it's missing or malformed,
+// in which case the caller should fall back to scanning by process name
+func readPIDFile(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// removePIDFile cleans up the pid file once the process has been confirmed
+// to have exited, so the next restore doesn't mistakenly think the server is
+// still running
+func removePIDFile(path string) {
+	os.Remove(path)
+}
+
+// isPIDRunning checks whether a process exists by PID rather than an
+// executable-name substring match, which is more reliable than the old check
+func isPIDRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := ps.FindProcess(pid)
+	if err != nil || process == nil {
+		return false
+	}
+	return true
+}
+
+// isServerRunning prefers the pid file to determine whether the server is
+// running; if the pid file is missing or its recorded process no longer
+// exists, falls back to isProcessRunning's executable-name scan
+func isServerRunning(serverDir, processName string) bool {
+	if pid := readPIDFile(pidFilePath(serverDir)); pid > 0 && isPIDRunning(pid) {
+		return true
+	}
+	return isProcessRunning(processName)
+}
+
+// waitForServerHealthy polls once per second for up to timeoutS to see whether the server
+// process has come up, used after restartServer to confirm this restart actually worked;
+// timeoutS<=0 defaults to 30 seconds. If the window elapses with no process detected,
+// returns false, and the caller should treat this restore as failed and swap the rollback
+// directory back into place
+func waitForServerHealthy(serverDir, processName string, timeoutS int) bool {
+	if timeoutS <= 0 {
+		timeoutS = 30
+	}
+	deadline := time.Now().Add(time.Duration(timeoutS) * time.Second)
+	for time.Now().Before(deadline) {
+		if isServerRunning(serverDir, processName) {
+			return true
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return isServerRunning(serverDir, processName)
+}
+
+// sendStopCommand tries to ask the running server to shut down gracefully
+// instead of passively polling. When RCON is configured it is tried first
+// (it doesn't require the server to have been started by this plugin);
+// otherwise it falls back to the platform-specific stop_command delivery
+// (named pipe etc.), and does nothing if neither is configured
+func sendStopCommand(serverDir string, restartConfig RestartServerConfig) error {
+	if restartConfig.RCON.Host != "" {
+		return sendRCONStopCommand(restartConfig.RCON.Host, restartConfig.RCON.Port, restartConfig.RCON.Password)
+	}
+	if restartConfig.StopCommand == "" {
+		return nil
+	}
+	return sendStopCommandPlatform(serverDir, restartConfig.StopCommand)
+}
+
+// pollUntilExit polls once a second for up to timeout to see if pid has
+// exited; returns true if it exits before the timeout, false otherwise
+// (the caller decides whether to escalate to a forced termination)
+func pollUntilExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !isPIDRunning(pid) {
+			return true
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return !isPIDRunning(pid)
+}
+
+// waitForProcessExit waits for the server process to exit, preferring to
+// poll the PID recorded in the pid file, and falling back to the old
+// process-name logic if the pid file is missing or its PID is gone.
+// When stopTimeoutS is greater than 0, it first gives that many seconds as a
+// "graceful shutdown" window, and if the process hasn't exited by then sends
+// a platform-specific forced termination signal (SIGTERM on Unix, CTRL_BREAK
+// on Windows) before continuing to poll indefinitely, so this never hangs
+// forever just because the graceful shutdown request didn't take effect.
+// This timeout escalation applies only to the PID branch; the process-name
+// fallback sends no termination signal and only polls indefinitely
+func waitForProcessExit(serverDir, processName string, stopTimeoutS int) {
+	pidPath := pidFilePath(serverDir)
+	pid := readPIDFile(pidPath)
+
+	if pid > 0 && isPIDRunning(pid) {
+		pluginPrint(fmt.Sprintf("Detected %s process is running (PID %d), waiting for server to shutdown", processName, pid), "WARNING")
+
+		if stopTimeoutS > 0 {
+			if pollUntilExit(pid, time.Duration(stopTimeoutS)*time.Second) {
+				removePIDFile(pidPath)
+				pluginPrint("Server has been shut down", "SUCCESS")
+				return
+			}
+			pluginPrint(fmt.Sprintf("Server still hasn't shut down after %d seconds, sending forced termination signal", stopTimeoutS), "WARNING")
+			if err := terminateProcessPlatform(pid); err != nil {
+				pluginPrint(fmt.Sprintf("Failed to send forced termination signal: %v", err), "WARNING")
+			}
+		}
+
+		for isPIDRunning(pid) {
+			time.Sleep(1 * time.Second)
+		}
+		removePIDFile(pidPath)
+		pluginPrint("Server has been shut down", "SUCCESS")
+		return
+	}
+
+	pluginPrint(fmt.Sprintf("Detected %s process is running, waiting for server to shutdown", processName), "WARNING")
+	for isProcessRunning(processName) {
+		time.Sleep(1 * time.Second)
+	}
+	pluginPrint("Server has been shut down", "SUCCESS")
+}
@@ -0,0 +1,80 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// stopFIFOName is the named pipe file backing the server's stdin, kept
+// alongside the start script in the server directory
+const stopFIFOName = "bedrock_server_stdin.fifo"
+
+// startServerProcessPlatform starts the script on Linux/macOS via
+// /bin/sh -c inside its own session (Setsid), so the server survives after
+// the restore process exits; stdout/stderr are redirected to the plugin log
+// file, and stdin is wired to a named pipe for sendStopCommandPlatform to
+// write the shutdown command to later
+func startServerProcessPlatform(serverDir, startScriptFullPath string, logFile *os.File) (int, error) {
+	fifoPath := filepath.Join(serverDir, stopFIFOName)
+	os.Remove(fifoPath)
+	if err := syscall.Mkfifo(fifoPath, 0600); err != nil {
+		return 0, fmt.Errorf("failed to create stdin named pipe: %v", err)
+	}
+
+	// Open the FIFO's read end in read-write mode to avoid blocking on a
+	// pure read-only open before a writer has connected
+	stdin, err := os.OpenFile(fifoPath, os.O_RDWR, os.ModeNamedPipe)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open stdin named pipe: %v", err)
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", startScriptFullPath)
+	cmd.Dir = serverDir
+	cmd.Stdin = stdin
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		stdin.Close()
+		return 0, fmt.Errorf("failed to start server process: %v", err)
+	}
+
+	return cmd.Process.Pid, nil
+}
+
+// terminateProcessPlatform sends SIGTERM to pid, used for forced termination
+// after a graceful shutdown timeout
+func terminateProcessPlatform(pid int) error {
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("failed to send SIGTERM: %v", err)
+	}
+	return nil
+}
+
+// sendStopCommandPlatform writes the stop command into the named pipe
+// backing the server's stdin. Requires the server process to have actually
+// been started by startServerProcessPlatform, otherwise the pipe won't exist
+func sendStopCommandPlatform(serverDir, stopCommand string) error {
+	fifoPath := filepath.Join(serverDir, stopFIFOName)
+	if _, err := os.Stat(fifoPath); err != nil {
+		return fmt.Errorf("server stdin pipe not found at %s (server may not have been started by this plugin): %v", fifoPath, err)
+	}
+
+	f, err := os.OpenFile(fifoPath, os.O_WRONLY, os.ModeNamedPipe)
+	if err != nil {
+		return fmt.Errorf("failed to open server stdin pipe: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(stopCommand + "\n"); err != nil {
+		return fmt.Errorf("failed to write stop command: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// createNewProcessGroup mirrors Windows' CREATE_NEW_PROCESS_GROUP creation
+// flag, detaching the child from the restore process's process group so it
+// isn't terminated when the restore process exits
+const createNewProcessGroup = 0x00000200
+
+// startServerProcessPlatform keeps the existing cmd /c start new-window
+// approach on Windows, additionally setting CREATE_NEW_PROCESS_GROUP so the
+// server process survives after the restore process exits
+func startServerProcessPlatform(serverDir, startScriptFullPath string, logFile *os.File) (int, error) {
+	cmd := exec.Command("C:\\Windows\\System32\\cmd.exe", "/c", "start", "/I", startScriptFullPath)
+	cmd.Dir = serverDir
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: createNewProcessGroup}
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start server process: %v", err)
+	}
+
+	return cmd.Process.Pid, nil
+}
+
+// sendStopCommandPlatform: stop_command over a named pipe isn't supported on
+// Windows yet
+func sendStopCommandPlatform(serverDir, stopCommand string) error {
+	return fmt.Errorf("stop_command is not supported on Windows yet, please stop the server manually")
+}
+
+var (
+	kernel32                     = syscall.NewLazyDLL("kernel32.dll")
+	procGenerateConsoleCtrlEvent = kernel32.NewProc("GenerateConsoleCtrlEvent")
+)
+
+// ctrlBreakEvent is CTRL_BREAK_EVENT, which can only be sent to a process
+// group started with CREATE_NEW_PROCESS_GROUP — exactly the creation flag
+// startServerProcessPlatform already sets
+const ctrlBreakEvent = 1
+
+// terminateProcessPlatform sends CTRL_BREAK to pid's process group via
+// GenerateConsoleCtrlEvent, used for forced termination after a graceful
+// shutdown timeout
+func terminateProcessPlatform(pid int) error {
+	r, _, err := procGenerateConsoleCtrlEvent.Call(uintptr(ctrlBreakEvent), uintptr(pid))
+	if r == 0 {
+		return fmt.Errorf("GenerateConsoleCtrlEvent failed: %v", err)
+	}
+	return nil
+}
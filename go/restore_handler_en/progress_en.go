@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress reports byte-level progress during long-running copy/extract/compress operations.
+// total<=0 means the caller has no way to know the total ahead of time (e.g. while the archive
+// format is still being sniffed from the stream); implementations should fall back to only
+// showing the bytes done so far instead of a percentage
+type Progress interface {
+	Update(done, total int64, currentPath string)
+}
+
+// throttledProgress is the default Progress implementation, throttled by both a time interval
+// and a percentage delta so large files don't print a log line on every chunk written
+type throttledProgress struct {
+	mu           sync.Mutex
+	interval     time.Duration
+	minPctDelta  float64
+	lastReportAt time.Time
+	lastPct      float64
+}
+
+// newThrottledProgress builds the default throttling policy: at least 500ms apart,
+// or a 5% change in percentage, whichever comes first
+func newThrottledProgress() *throttledProgress {
+	return &throttledProgress{
+		interval:    500 * time.Millisecond,
+		minPctDelta: 5,
+	}
+}
+
+func (p *throttledProgress) Update(done, total int64, currentPath string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	sinceLast := now.Sub(p.lastReportAt)
+
+	if total <= 0 {
+		if sinceLast < p.interval {
+			return
+		}
+		p.lastReportAt = now
+		pluginPrint(fmt.Sprintf("Progress: %d bytes processed - %s", done, currentPath), "INFO")
+		return
+	}
+
+	pct := float64(done) / float64(total) * 100
+	isDone := done >= total
+	if sinceLast < p.interval && pct-p.lastPct < p.minPctDelta && !isDone {
+		return
+	}
+
+	p.lastReportAt = now
+	p.lastPct = pct
+	pluginPrint(fmt.Sprintf("Progress: %.1f%% (%d/%d) - %s", pct, done, total, currentPath), "INFO")
+}
+
+// countingReader wraps io.Reader, atomically adding each Read's byte count to *done and
+// reporting it to Progress. done is a pointer to a counter shared by the caller, so multiple
+// countingReaders can share the same counter across concurrent workers
+type countingReader struct {
+	r        io.Reader
+	done     *int64
+	total    int64
+	path     string
+	progress Progress
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		total := atomic.AddInt64(c.done, int64(n))
+		if c.progress != nil {
+			c.progress.Update(total, c.total, c.path)
+		}
+	}
+	return n, err
+}
+
+// countingWriter mirrors countingReader for cases where compression is driven internally by a
+// third-party library and we can't get a per-file callback (reports bytes written to the
+// compressed output, not the original size)
+type countingWriter struct {
+	w        io.Writer
+	done     *int64
+	total    int64
+	path     string
+	progress Progress
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if n > 0 {
+		total := atomic.AddInt64(c.done, int64(n))
+		if c.progress != nil {
+			c.progress.Update(total, c.total, c.path)
+		}
+	}
+	return n, err
+}
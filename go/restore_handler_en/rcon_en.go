@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	rconTypeAuth        = 3
+	rconTypeExecCommand = 2
+	rconTimeout         = 5 * time.Second
+)
+
+// sendRCONStopCommand authenticates against host:port over the Source RCON
+// protocol and sends a "stop" command, requesting bedrock_server shut down
+// gracefully. Unlike the stop_command named pipe, this doesn't require the
+// server process to have been started by this plugin, only that
+// bedrock_server itself has RCON enabled
+func sendRCONStopCommand(host string, port int, password string) error {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), rconTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RCON %s:%d: %v", host, port, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(rconTimeout))
+
+	if err := writeRCONPacket(conn, 1, rconTypeAuth, password); err != nil {
+		return fmt.Errorf("failed to send RCON auth packet: %v", err)
+	}
+	authReqID, err := readRCONPacket(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read RCON auth response: %v", err)
+	}
+	if authReqID == -1 {
+		return fmt.Errorf("RCON authentication failed, check the password")
+	}
+
+	if err := writeRCONPacket(conn, 2, rconTypeExecCommand, "stop"); err != nil {
+		return fmt.Errorf("failed to send RCON stop command: %v", err)
+	}
+	if _, err := readRCONPacket(conn); err != nil {
+		return fmt.Errorf("failed to read RCON command response: %v", err)
+	}
+	return nil
+}
+
+// writeRCONPacket writes a packet in the Source RCON wire format: int32
+// length + int32 request ID + int32 type + payload + two null terminator bytes
+func writeRCONPacket(w io.Writer, requestID, packetType int32, payload string) error {
+	body := []byte(payload)
+	size := int32(4 + 4 + len(body) + 2)
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, size)
+	binary.Write(buf, binary.LittleEndian, requestID)
+	binary.Write(buf, binary.LittleEndian, packetType)
+	buf.Write(body)
+	buf.Write([]byte{0, 0})
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// readRCONPacket reads one RCON response packet and returns its request ID
+// (-1 on failed auth), discarding the payload since we only care whether the
+// stop command was accepted, not the server's text reply
+func readRCONPacket(r io.Reader) (int32, error) {
+	var size int32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return 0, err
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(body[0:4])), nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimestampPattern matches the _YYYYMMDD_HHMMSS timestamp suffix of a backup ID, covering
+// both the existing before_restore_ prefix and any other prefix daemon.go's scheduled job may
+// produce in the future
+var backupTimestampPattern = regexp.MustCompile(`_(\d{8}_\d{6})$`)
+
+// parseBackupTimestamp extracts the filename-encoded timestamp from a backup ID (e.g.
+// before_restore_20260715_120000). pruneOldBackups buckets on this rather than
+// manifest.CreatedAt, so age can still be judged even if a manifest is missing or corrupted
+func parseBackupTimestamp(backupID string) (time.Time, error) {
+	m := backupTimestampPattern.FindStringSubmatch(backupID)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("failed to parse a timestamp out of backup ID %s", backupID)
+	}
+	return time.Parse("20060102_150405", m[1])
+}
+
+// backupRecord summarizes one backup (a full archive or a single incremental patch layer) with
+// everything the retention policy needs to decide whether to keep it
+type backupRecord struct {
+	BackupID    string
+	Path        string
+	ArchivePath string
+	CreatedAt   time.Time
+	Manifest    *BackupManifest
+	Size        int64
+}
+
+// backupArchiveFilePath returns the actual archive file path for a backup: an incremental layer
+// is always .patch.tar.gz, a full backup is looked up by its conventional extension in the same directory
+func backupArchiveFilePath(backupIDPath string, manifest *BackupManifest) string {
+	if manifest.Mode == backupModeIncremental {
+		return backupIDPath + patchExtension
+	}
+	return resolveBackupArchivePath(backupIDPath)
+}
+
+// backupTotalSize sums the local disk footprint of one backup: the archive itself plus its
+// metadata/manifest/whiteouts sidecars (missing sidecars are simply skipped), used for the
+// max_total_size_gb cap
+func backupTotalSize(backupIDPath, archivePath string) int64 {
+	var total int64
+	for _, p := range []string{archivePath, archivePath + metadataSuffix, backupIDPath + manifestSuffix, backupIDPath + ".whiteouts.json"} {
+		if info, err := os.Stat(p); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// listBackupsForWorld enumerates every backup (the full backup plus each incremental layer) for a
+// given world under backupDir, for pruneOldBackups to run its retention policy against. Unlike
+// latestBackupForWorld, it returns every record instead of only the newest one
+func listBackupsForWorld(backupDir, worldName string) ([]*backupRecord, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []*backupRecord
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), manifestSuffix) {
+			continue
+		}
+
+		backupID := strings.TrimSuffix(entry.Name(), manifestSuffix)
+		backupPath := filepath.Join(backupDir, backupID)
+		manifest, err := readManifest(backupPath)
+		if err != nil || manifest == nil || manifest.WorldName != worldName {
+			continue
+		}
+
+		createdAt, err := parseBackupTimestamp(backupID)
+		if err != nil {
+			// the filename doesn't match the expected format (e.g. a legacy manual rename); fall
+			// back to the timestamp recorded in the manifest
+			createdAt = manifest.CreatedAt
+		}
+
+		archivePath := backupArchiveFilePath(backupPath, manifest)
+		records = append(records, &backupRecord{
+			BackupID:    backupID,
+			Path:        backupPath,
+			ArchivePath: archivePath,
+			CreatedAt:   createdAt,
+			Manifest:    manifest,
+			Size:        backupTotalSize(backupPath, archivePath),
+		})
+	}
+	return records, nil
+}
+
+func dailyBucketKey(t time.Time) string   { return t.Format("2006-01-02") }
+func monthlyBucketKey(t time.Time) string { return t.Format("2006-01") }
+func yearlyBucketKey(t time.Time) string  { return t.Format("2006") }
+
+func weeklyBucketKey(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// keepNewestPerBucket groups records (which must already be sorted newest-first by CreatedAt) by
+// bucketKey and keeps the newest n in each group in the keep set; n<=0 disables this tier
+func keepNewestPerBucket(records []*backupRecord, n int, bucketKey func(time.Time) string, keep map[string]bool) {
+	if n <= 0 {
+		return
+	}
+	count := make(map[string]int)
+	for _, r := range records {
+		k := bucketKey(r.CreatedAt)
+		if count[k] < n {
+			keep[r.BackupID] = true
+			count[k]++
+		}
+	}
+}
+
+// expandWithAncestors walks ParentBackupID back from every backup already in the keep set and
+// keeps its ancestors too; otherwise deleting the parent of a kept incremental backup would leave
+// it impossible to restore
+func expandWithAncestors(records []*backupRecord, keep map[string]bool) {
+	byID := make(map[string]*backupRecord, len(records))
+	for _, r := range records {
+		byID[r.BackupID] = r
+	}
+
+	ids := make([]string, 0, len(keep))
+	for id := range keep {
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		cur := byID[id]
+		for cur != nil && cur.Manifest.Mode == backupModeIncremental && cur.Manifest.ParentBackupID != "" {
+			parentID := cur.Manifest.ParentBackupID
+			if keep[parentID] {
+				break
+			}
+			keep[parentID] = true
+			cur = byID[parentID]
+		}
+	}
+}
+
+// pruneBySizeCap runs after the generational decision: if the local backup total still exceeds
+// capBytes, it keeps evicting from the keep set oldest-first until back under the cap. A backup
+// still depended on by another kept backup's incremental chain is skipped, which may leave the
+// total over the cap rather than break restorability
+func pruneBySizeCap(records []*backupRecord, keep map[string]bool, capBytes int64) {
+	childCount := make(map[string]int)
+	var total int64
+	for _, r := range records {
+		if keep[r.BackupID] {
+			total += r.Size
+			if r.Manifest.Mode == backupModeIncremental {
+				childCount[r.Manifest.ParentBackupID]++
+			}
+		}
+	}
+
+	// records are sorted newest-first, so walking from the end is oldest-first
+	for i := len(records) - 1; i >= 0 && total > capBytes; i-- {
+		r := records[i]
+		if !keep[r.BackupID] || childCount[r.BackupID] > 0 {
+			continue
+		}
+		keep[r.BackupID] = false
+		total -= r.Size
+		if r.Manifest.Mode == backupModeIncremental {
+			childCount[r.Manifest.ParentBackupID]--
+		}
+	}
+}
+
+// pruneOldBackups runs the generational (grandfather-father-son) retention policy after
+// backupCurrentWorld writes a new archive: backups are bucketed by the timestamp in their
+// filename into day/week/month/year, the newest N in each bucket are kept, plus keep_last
+// unconditionally-kept most recent backups, and the rest are deleted. If max_total_size_gb is
+// set, backups are additionally deleted oldest-first until the local total is back under the cap.
+// Only touches files under the local backupDir — copies already pushed to remote storage are untouched
+func pruneOldBackups(backupDir, worldName string, policy RetentionConfig) error {
+	records, err := listBackupsForWorld(backupDir, worldName)
+	if err != nil {
+		return fmt.Errorf("failed to list existing backups: %v", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.After(records[j].CreatedAt) })
+
+	keep := make(map[string]bool)
+	for i, r := range records {
+		if i < policy.KeepLast {
+			keep[r.BackupID] = true
+		}
+	}
+	keepNewestPerBucket(records, policy.KeepDaily, dailyBucketKey, keep)
+	keepNewestPerBucket(records, policy.KeepWeekly, weeklyBucketKey, keep)
+	keepNewestPerBucket(records, policy.KeepMonthly, monthlyBucketKey, keep)
+	keepNewestPerBucket(records, policy.KeepYearly, yearlyBucketKey, keep)
+	expandWithAncestors(records, keep)
+
+	if policy.MaxTotalSizeGB > 0 {
+		pruneBySizeCap(records, keep, int64(policy.MaxTotalSizeGB*1024*1024*1024))
+	}
+
+	var keptNames, deletedNames []string
+	for _, r := range records {
+		if keep[r.BackupID] {
+			keptNames = append(keptNames, r.BackupID)
+			continue
+		}
+		if err := deleteBackupFiles(r.Path, r.ArchivePath); err != nil {
+			pluginPrint(fmt.Sprintf("failed to delete expired backup %s: %v", r.BackupID, err), "WARNING")
+			continue
+		}
+		deletedNames = append(deletedNames, r.BackupID)
+	}
+
+	if len(deletedNames) > 0 {
+		pluginPrint(fmt.Sprintf("retention policy applied: kept %d backups (%s), deleted %d expired backups (%s)",
+			len(keptNames), strings.Join(keptNames, ", "), len(deletedNames), strings.Join(deletedNames, ", ")), "INFO")
+	} else {
+		pluginPrint(fmt.Sprintf("retention policy applied: kept %d backups, nothing expired to delete", len(keptNames)), "INFO")
+	}
+	return nil
+}
+
+// deleteBackupFiles deletes a backup's archive plus its metadata/manifest/whiteouts sidecars; a
+// file that's already missing is not an error
+func deleteBackupFiles(backupIDPath, archivePath string) error {
+	for _, p := range []string{archivePath, archivePath + metadataSuffix, backupIDPath + manifestSuffix, backupIDPath + ".whiteouts.json"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %v", p, err)
+		}
+	}
+	return nil
+}
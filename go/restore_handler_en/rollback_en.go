@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// newWorldDirSuffix and rollbackWorldDirSuffix are the naming convention for the sibling
+// staging/rollback directories used by the in-place restore swap; the timestamp suffix
+// ensures repeated restores of the same world never collide with each other
+const (
+	newWorldDirSuffix      = ".new-"
+	rollbackWorldDirSuffix = ".rollback-"
+)
+
+// stagingWorldDir returns the staging directory this restore uses to land its
+// extracted/copied result: a sibling directory under worlds/ named after the world plus
+// a timestamp suffix
+func stagingWorldDir(worldsDir, worldName, timestamp string) string {
+	return filepath.Join(worldsDir, worldName+newWorldDirSuffix+timestamp)
+}
+
+// rollbackWorldDirFor returns the directory the current world directory is moved to
+// before swapping in the new one; the restore swaps this back in if the swap itself
+// fails or the post-restart health check times out
+func rollbackWorldDirFor(worldsDir, worldName, timestamp string) string {
+	return filepath.Join(worldsDir, worldName+rollbackWorldDirSuffix+timestamp)
+}
+
+// rollbackWorld swaps the rollback directory back into currentWorldDir's place, used to
+// recover to the pre-restore state after a failed in-place swap or a health check timeout.
+// currentWorldDir is expected to hold the new world this restore just swapped in
+func rollbackWorld(rollbackDir, currentWorldDir string) error {
+	if _, err := os.Stat(rollbackDir); err != nil {
+		return fmt.Errorf("rollback directory does not exist: %v", err)
+	}
+	if _, err := os.Stat(currentWorldDir); err == nil {
+		if err := removeDir(currentWorldDir); err != nil {
+			return fmt.Errorf("failed to remove the failed new world directory: %v", err)
+		}
+	}
+	if err := os.Rename(rollbackDir, currentWorldDir); err != nil {
+		return fmt.Errorf("failed to swap the rollback directory back into the world directory: %v", err)
+	}
+	return nil
+}
+
+// pruneRollbackDirs cleans up the historical rollback directories a world has
+// accumulated under worldsDir, keeping only the most recent keep of them;
+// keep<=0 disables automatic cleanup entirely, leaving it for the user to handle manually
+func pruneRollbackDirs(worldsDir, worldName string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(worldsDir)
+	if err != nil {
+		return fmt.Errorf("failed to list worlds directory: %v", err)
+	}
+
+	prefix := worldName + rollbackWorldDirSuffix
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
+			names = append(names, entry.Name())
+		}
+	}
+	// the directory name ends in a YYYYMMDD_HHMMSS timestamp, so lexical order is
+	// chronological order
+	sort.Strings(names)
+
+	if len(names) <= keep {
+		return nil
+	}
+
+	for _, name := range names[:len(names)-keep] {
+		path := filepath.Join(worldsDir, name)
+		if err := removeDir(path); err != nil {
+			return fmt.Errorf("failed to delete historical rollback directory %s: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// nbtTagCompound is the NBT tag type ID for a compound tag; Bedrock's level.dat root tag is
+// always a compound, used for a cheap "does this look like an NBT file" check rather than a
+// full parse of the NBT tree
+const nbtTagCompound = 0x0a
+
+// verifyWorldDirStructure checks, before swapping the extracted world directory into worlds/,
+// that it has at least the basic file structure a Bedrock world should have (level.dat,
+// levelname.txt, and at least one .ldb file or CURRENT under db/), so a truncated/corrupt
+// archive that "extracted successfully" but only landed half a file tree doesn't go on to
+// wipe out the existing world directory at the copy step
+func verifyWorldDirStructure(worldDir string) error {
+	for _, name := range []string{"level.dat", "levelname.txt"} {
+		path := filepath.Join(worldDir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("extracted world directory is missing required file %s: %v", name, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s should be a file in the extracted world directory, but is a directory", name)
+		}
+	}
+
+	dbDir := filepath.Join(worldDir, "db")
+	entries, err := os.ReadDir(dbDir)
+	if err != nil {
+		return fmt.Errorf("extracted world directory is missing the db subdirectory: %v", err)
+	}
+
+	hasLDBOrCurrent := false
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if entry.Name() == "CURRENT" || filepath.Ext(entry.Name()) == ".ldb" {
+			hasLDBOrCurrent = true
+			break
+		}
+	}
+	if !hasLDBOrCurrent {
+		return fmt.Errorf("no CURRENT file or any .ldb file found under the extracted world directory's db subdirectory, world data may be incomplete")
+	}
+
+	return nil
+}
+
+// verifyLevelDatHeader performs a lightweight sanity check on level.dat rather than a full NBT
+// parse: Bedrock's level.dat format is a 4-byte little-endian version followed by a 4-byte
+// little-endian payload length, then the NBT data itself, whose root tag is always a compound
+// (tag type 0x0a). If any of these fields can't be read or don't line up, this level.dat is
+// very likely truncated or corrupted
+func verifyLevelDatHeader(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read level.dat: %v", err)
+	}
+	if len(data) < 9 {
+		return fmt.Errorf("level.dat is too small (%d bytes) to be a complete world save", len(data))
+	}
+
+	payloadLen := int(binary.LittleEndian.Uint32(data[4:8]))
+	if payloadLen <= 0 {
+		return fmt.Errorf("level.dat header records an invalid payload length: %d", payloadLen)
+	}
+	if 8+payloadLen > len(data) {
+		return fmt.Errorf("level.dat header's payload length (%d bytes) exceeds the data actually present after the header (%d bytes), the file may be truncated", payloadLen, len(data)-8)
+	}
+
+	if data[8] != nbtTagCompound {
+		return fmt.Errorf("level.dat's NBT root tag type is not compound (0x0a), got 0x%02x instead", data[8])
+	}
+
+	return nil
+}
+
+// verifyRestoredWorld is the single entry point combining verifyWorldDirStructure and
+// verifyLevelDatHeader, called right before the extracted world is swapped into the existing
+// world directory's place; a failure aborts the restore without touching the current world
+// directory
+func verifyRestoredWorld(worldDir string) error {
+	if err := verifyWorldDirStructure(worldDir); err != nil {
+		return err
+	}
+	return verifyLevelDatHeader(filepath.Join(worldDir, "level.dat"))
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdTOCSuffix is the suffix for the sidecar index file that accompanies a
+// chunked zstd archive, kept next to the archive file - the same convention
+// as the manifest/whiteouts sidecars
+const zstdTOCSuffix = ".toc.json"
+
+// ZstdChunkEntry records where a single file's independent zstd frame sits
+// inside a chunked archive, so extraction can skip files it doesn't need
+// instead of streaming the whole archive from the start
+type ZstdChunkEntry struct {
+	Path             string `json:"path"`
+	Offset           int64  `json:"offset"`
+	CompressedSize   int64  `json:"compressed_size"`
+	UncompressedSize int64  `json:"uncompressed_size"`
+}
+
+// ZstdTOC is the full frame index produced by one tar.zst chunked compression run
+type ZstdTOC struct {
+	Entries []ZstdChunkEntry `json:"entries"`
+}
+
+// compressWithTarZstd packs each file under srcDir into its own single-file tar
+// and compresses it into its own independent zstd frame, concatenating the
+// frames into dst while writing a TOC alongside that records each frame's
+// offset. Unlike compressing the whole directory as one big tar then running
+// it through zstd once, this lets later extraction seek straight to a single
+// file's frame without decoding everything before it - the same idea
+// zstd-chunked uses for container image layers
+func compressWithTarZstd(ctx context.Context, srcDir, dst string, progress Progress) error {
+	var files []string
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk source directory: %v", err)
+	}
+	sort.Strings(files)
+
+	var totalBytes int64
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat file %s: %v", path, err)
+		}
+		totalBytes += info.Size()
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create archive file: %v", err)
+	}
+	defer out.Close()
+
+	compression := currentConfig().Compression
+	concurrency := compression.Threads
+	if concurrency <= 0 {
+		concurrency = globalConfig.MaxWorkers
+	}
+	if concurrency <= 0 {
+		concurrency = defaultMaxWorkers
+	}
+
+	level := compression.Level
+
+	pluginPrint(fmt.Sprintf("Compressing with chunked zstd (concurrency %d, level %d): %s --> %s", concurrency, level, srcDir, dst), "INFO")
+
+	var toc ZstdTOC
+	var offset, doneBytes int64
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to compute relative path: %v", err)
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		frame, uncompressedSize, err := encodeZstdFrame(path, relPath, concurrency, level)
+		if err != nil {
+			return fmt.Errorf("failed to encode zstd frame %s: %v", relPath, err)
+		}
+
+		if _, err := out.Write(frame); err != nil {
+			return fmt.Errorf("failed to write compressed frame %s: %v", relPath, err)
+		}
+
+		toc.Entries = append(toc.Entries, ZstdChunkEntry{
+			Path:             relPath,
+			Offset:           offset,
+			CompressedSize:   int64(len(frame)),
+			UncompressedSize: uncompressedSize,
+		})
+		offset += int64(len(frame))
+
+		doneBytes += uncompressedSize
+		if progress != nil {
+			progress.Update(doneBytes, totalBytes, relPath)
+		}
+	}
+
+	if err := writeZstdTOC(dst, &toc); err != nil {
+		return err
+	}
+
+	pluginPrint("Chunked zstd compression completed", "SUCCESS")
+	pluginPrint(fmt.Sprintf("Backup file saved: %s", dst), "SUCCESS")
+	return nil
+}
+
+// encodeZstdFrame builds a self-contained zstd frame for a single file (a tar
+// stream holding just that one file), so it can later be located and decoded
+// independently of the other frames in the archive
+func encodeZstdFrame(path, relPath string, concurrency, level int) ([]byte, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return nil, 0, err
+	}
+	header.Name = relPath
+
+	if err := tw.WriteHeader(header); err != nil {
+		return nil, 0, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, copyErr := io.Copy(tw, file)
+	file.Close()
+	if copyErr != nil {
+		return nil, 0, copyErr
+	}
+	if err := tw.Close(); err != nil {
+		return nil, 0, err
+	}
+
+	opts := []zstd.EOption{zstd.WithEncoderConcurrency(concurrency)}
+	if level > 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevel(level)))
+	}
+
+	var zstdBuf bytes.Buffer
+	enc, err := zstd.NewWriter(&zstdBuf, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := enc.Write(tarBuf.Bytes()); err != nil {
+		enc.Close()
+		return nil, 0, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, 0, err
+	}
+
+	return zstdBuf.Bytes(), info.Size(), nil
+}
+
+// writeZstdTOC writes the frame index to the sidecar next to the archive file
+func writeZstdTOC(archivePath string, toc *ZstdTOC) error {
+	data, err := json.MarshalIndent(toc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal zstd TOC: %v", err)
+	}
+	if err := os.WriteFile(archivePath+zstdTOCSuffix, data, 0644); err != nil {
+		return fmt.Errorf("failed to write zstd TOC: %v", err)
+	}
+	return nil
+}
+
+// readZstdTOC reads the frame index next to the archive file; returns nil
+// instead of an error when it's missing, since not every tar.zst archive was
+// produced in chunked form (e.g. an older single-frame zstd stream)
+func readZstdTOC(archivePath string) (*ZstdTOC, error) {
+	data, err := os.ReadFile(archivePath + zstdTOCSuffix)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zstd TOC: %v", err)
+	}
+
+	var toc ZstdTOC
+	if err := json.Unmarshal(data, &toc); err != nil {
+		return nil, fmt.Errorf("failed to parse zstd TOC: %v", err)
+	}
+	return &toc, nil
+}
+
+// extractTarZstdChunked extracts a chunked zstd archive on demand using its
+// TOC. When onlyGlob is non-empty, only matching frames are seeked to and
+// decoded; the rest are skipped entirely without any decompression cost
+func extractTarZstdChunked(ctx context.Context, archivePath, destDir, onlyGlob string, progress Progress) error {
+	toc, err := readZstdTOC(archivePath)
+	if err != nil {
+		return err
+	}
+	if toc == nil {
+		return fmt.Errorf("chunked zstd archive is missing its TOC sidecar: %s", archivePath)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer file.Close()
+
+	var entries []ZstdChunkEntry
+	var totalBytes int64
+	for _, entry := range toc.Entries {
+		if onlyGlob != "" {
+			matched, err := filepath.Match(onlyGlob, entry.Path)
+			if err != nil {
+				return fmt.Errorf("invalid -only match pattern: %v", err)
+			}
+			if !matched {
+				continue
+			}
+		}
+		entries = append(entries, entry)
+		totalBytes += entry.UncompressedSize
+	}
+
+	if onlyGlob != "" {
+		pluginPrint(fmt.Sprintf("-only filter %s selected %d/%d files, remaining frames skipped entirely", onlyGlob, len(entries), len(toc.Entries)), "INFO")
+	}
+
+	var doneBytes int64
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if _, err := file.Seek(entry.Offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek to frame offset %s: %v", entry.Path, err)
+		}
+
+		frameReader := io.LimitReader(file, entry.CompressedSize)
+		if err := extractZstdFrame(frameReader, destDir, &doneBytes, totalBytes, progress); err != nil {
+			return fmt.Errorf("failed to extract frame %s: %v", entry.Path, err)
+		}
+	}
+
+	pluginPrint("Chunked zstd extraction completed", "SUCCESS")
+	return nil
+}
+
+// extractZstdFrame decodes a single independent zstd frame (a tar stream
+// holding just one file) and writes it to disk
+func extractZstdFrame(r io.Reader, destDir string, doneBytes *int64, totalBytes int64, progress Progress) error {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer dec.Close()
+
+	tr := tar.NewReader(dec)
+	header, err := tr.Next()
+	if err != nil {
+		return err
+	}
+
+	targetPath, err := safeExtractEntryPath(destDir, filepath.FromSlash(header.Name))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+
+	outFile, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
+	if err != nil {
+		return err
+	}
+	defer outFile.Close()
+
+	reader := &countingReader{r: tr, done: doneBytes, total: totalBytes, path: header.Name, progress: progress}
+	if _, err := io.Copy(outFile, reader); err != nil {
+		return err
+	}
+
+	return nil
+}